@@ -1,36 +1,65 @@
 package cli
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/infrasonar/infrasonar-cli/infrasonar"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StatePresent = "present"
+	StateAbsent  = "absent"
+)
+
+// Presence marks an item in an input file for removal, with either
+// `state: absent` or `remove: true`.
+type Presence struct {
+	State  string `json:"state,omitempty" yaml:"state,omitempty"`
+	Remove bool   `json:"remove,omitempty" yaml:"remove,omitempty"`
+}
+
+func (p *Presence) IsAbsent() bool {
+	return p.State == StateAbsent || p.Remove
+}
+
+func (p *Presence) Check() error {
+	switch p.State {
+	case "", StatePresent, StateAbsent:
+		return nil
+	}
+	return fmt.Errorf("invalid state '%s'; must be one of {present,absent}", p.State)
+}
 
 type TDisabledChecks struct {
 	Collector string `json:"collector" yaml:"collector"`
 	Check     string `json:"check" yaml:"check"`
+	Presence  `yaml:",inline"`
 }
 
 type TCollector struct {
-	Key    string         `json:"key" yaml:"key"`
-	Config map[string]any `json:"config,omitempty" yaml:"config,omitempty"`
+	Key      string         `json:"key" yaml:"key"`
+	Config   map[string]any `json:"config,omitempty" yaml:"config,omitempty"`
+	Presence `yaml:",inline"`
 }
 
 type TProperty struct {
-	Key   string `json:"key" yaml:"key"`
-	Value any    `json:"value" yaml:"value"`
+	Key      string `json:"key" yaml:"key"`
+	Value    any    `json:"value" yaml:"value"`
+	Presence `yaml:",inline"`
 }
 
-type AssetApi struct {
-	Id             int               `json:"id"`
-	ContainerId    int               `json:"container"`
-	Name           string            `json:"name"`
-	Zone           *int              `json:"zone"`
-	Labels         []int             `json:"labels"`
-	Description    string            `json:"description"`
-	Mode           string            `json:"mode"`
-	Kind           string            `json:"kind"`
-	Collectors     []TCollector      `json:"collectors"`
-	DisabledChecks []TDisabledChecks `json:"disabledChecks"`
-	Properties     []TProperty       `json:"properties"`
+// labelRef is a label in the labels of an asset, written as a mapping
+// instead of just the label key.
+type labelRef struct {
+	Key      string `json:"key" yaml:"key"`
+	Presence `yaml:",inline"`
 }
 
+type AssetApi = infrasonar.Asset
+
 type AssetCli struct {
 	Id             int                `json:"id,omitempty" yaml:"id,omitempty"`
 	Name           string             `json:"name,omitempty" yaml:"name,omitempty"`
@@ -42,6 +71,118 @@ type AssetCli struct {
 	Collectors     *[]TCollector      `json:"collectors,omitempty" yaml:"collectors,omitempty"`
 	DisabledChecks *[]TDisabledChecks `json:"disabledChecks,omitempty" yaml:"disabledChecks,omitempty"`
 	Properties     *[]TProperty       `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Presence       `yaml:",inline"`
+
+	// Label keys marked for removal in the input file
+	AbsentLabels []string `json:"-" yaml:"-"`
+}
+
+// assetCli has no methods, so it can be decoded without recursion.
+type assetCli AssetCli
+
+// addLabelRef adds a label in the form of a mapping to the labels, or to the
+// absent labels when it is marked for removal.
+func (a *AssetCli) addLabelRef(ref *labelRef, labels *[]string) error {
+	if ref.Key == "" {
+		return fmt.Errorf("asset '%s' has a label without a key", a.Str())
+	}
+	if err := ref.Check(); err != nil {
+		return fmt.Errorf("label '%s' on asset '%s' has an %s", ref.Key, a.Str(), err)
+	}
+	if ref.IsAbsent() {
+		a.AbsentLabels = append(a.AbsentLabels, ref.Key)
+	} else {
+		*labels = append(*labels, ref.Key)
+	}
+	return nil
+}
+
+// UnmarshalYAML accepts labels as a key or as a mapping with a key and a
+// state.
+func (a *AssetCli) UnmarshalYAML(node *yaml.Node) error {
+	var refs []*labelRef
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if node.Content[i].Value != "labels" || value.Kind != yaml.SequenceNode {
+				continue
+			}
+			// Decode the mappings here and the keys as usual
+			seq := *value
+			seq.Content = nil
+			for _, item := range value.Content {
+				if item.Kind != yaml.MappingNode {
+					seq.Content = append(seq.Content, item)
+					continue
+				}
+				var ref labelRef
+				if err := item.Decode(&ref); err != nil {
+					return err
+				}
+				refs = append(refs, &ref)
+			}
+			clone := *node
+			clone.Content = slices.Clone(node.Content)
+			clone.Content[i+1] = &seq
+			node = &clone
+			break
+		}
+	}
+	if err := node.Decode((*assetCli)(a)); err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if a.Labels == nil {
+			a.Labels = &[]string{}
+		}
+		if err := a.addLabelRef(ref, a.Labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts labels as a key or as an object with a key and a
+// state.
+func (a *AssetCli) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		*assetCli
+		Labels []json.RawMessage `json:"labels"`
+	}
+	raw.assetCli = (*assetCli)(a)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Labels == nil {
+		return nil
+	}
+	labels := []string{}
+	for _, item := range raw.Labels {
+		var key string
+		if json.Unmarshal(item, &key) == nil {
+			labels = append(labels, key)
+			continue
+		}
+		var ref labelRef
+		if err := json.Unmarshal(item, &ref); err != nil {
+			return err
+		}
+		if err := a.addLabelRef(&ref, &labels); err != nil {
+			return err
+		}
+	}
+	a.Labels = &labels
+	return nil
+}
+
+// HasAbsentLabelId returns true if the label is marked for removal.
+func (a *AssetCli) HasAbsentLabelId(labelId int, lm *LabelMap) bool {
+	for _, key := range a.AbsentLabels {
+		if label := lm.LabelByKey(key); label != nil && label.Id == labelId {
+			return true
+		}
+	}
+	return false
 }
 
 func (a *AssetCli) Str() string {
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestAssetCliUnmarshal(t *testing.T) {
+	tests := []struct {
+		name       string
+		yaml       string
+		json       string
+		wantLabels *[]string
+		wantAbsent []string
+		wantName   string
+		wantErr    bool
+	}{
+		{
+			name:       "label keys",
+			yaml:       "name: web1\nlabels: [prod, web]",
+			json:       `{"name": "web1", "labels": ["prod", "web"]}`,
+			wantName:   "web1",
+			wantLabels: &[]string{"prod", "web"},
+		},
+		{
+			name:       "label mappings",
+			yaml:       "name: web1\nlabels:\n  - key: prod\n  - key: web\n    state: present",
+			json:       `{"name": "web1", "labels": [{"key": "prod"}, {"key": "web", "state": "present"}]}`,
+			wantName:   "web1",
+			wantLabels: &[]string{"prod", "web"},
+		},
+		{
+			name:       "absent labels",
+			yaml:       "name: web1\nlabels:\n  - prod\n  - key: test\n    state: absent\n  - key: temp\n    remove: true",
+			json:       `{"name": "web1", "labels": ["prod", {"key": "test", "state": "absent"}, {"key": "temp", "remove": true}]}`,
+			wantName:   "web1",
+			wantLabels: &[]string{"prod"},
+			wantAbsent: []string{"test", "temp"},
+		},
+		{
+			name:       "only absent labels",
+			yaml:       "labels:\n  - key: test\n    state: absent",
+			json:       `{"labels": [{"key": "test", "state": "absent"}]}`,
+			wantLabels: &[]string{},
+			wantAbsent: []string{"test"},
+		},
+		{
+			name:     "no labels",
+			yaml:     "name: web1",
+			json:     `{"name": "web1"}`,
+			wantName: "web1",
+		},
+		{
+			name:    "label without a key",
+			yaml:    "labels:\n  - state: absent",
+			json:    `{"labels": [{"state": "absent"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid label state",
+			yaml:    "labels:\n  - key: test\n    state: gone",
+			json:    `{"labels": [{"key": "test", "state": "gone"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, format := range []string{"yaml", "json"} {
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				var a AssetCli
+				var err error
+				if format == "yaml" {
+					err = yaml.Unmarshal([]byte(tt.yaml), &a)
+				} else {
+					err = json.Unmarshal([]byte(tt.json), &a)
+				}
+				if tt.wantErr {
+					if err == nil {
+						t.Fatal("expected an error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if a.Name != tt.wantName {
+					t.Errorf("name = %q, want %q", a.Name, tt.wantName)
+				}
+				if !reflect.DeepEqual(a.Labels, tt.wantLabels) {
+					t.Errorf("labels = %v, want %v", a.Labels, tt.wantLabels)
+				}
+				if !reflect.DeepEqual(a.AbsentLabels, tt.wantAbsent) {
+					t.Errorf("absent labels = %v, want %v", a.AbsentLabels, tt.wantAbsent)
+				}
+			})
+		}
+	}
+}
+
+func TestAssetCliUnmarshalItems(t *testing.T) {
+	data := `
+id: 11
+state: absent
+collectors:
+  - key: tcp
+    remove: true
+disabledChecks:
+  - collector: ping
+    check: ping
+    state: absent
+`
+	var a AssetCli
+	if err := yaml.Unmarshal([]byte(data), &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Id != 11 || !a.IsAbsent() {
+		t.Errorf("asset = %d, absent %v", a.Id, a.IsAbsent())
+	}
+	if a.Collectors == nil || !(*a.Collectors)[0].IsAbsent() {
+		t.Error("collector is not absent")
+	}
+	if a.DisabledChecks == nil || !(*a.DisabledChecks)[0].IsAbsent() {
+		t.Error("disabled check is not absent")
+	}
+}
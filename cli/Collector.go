@@ -1,15 +1,5 @@
 package cli
 
-type Collector struct {
-	Key  string `json:"key,omitempty" yaml:"key,omitempty"`
-	Name string `json:"name,omitempty" yaml:"name,omitempty"`
-	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
-	Info string `json:"info,omitempty" yaml:"info,omitempty"`
+import "github.com/infrasonar/infrasonar-cli/infrasonar"
 
-	Checks  []string `json:"checks" yaml:"checks"`
-	Options []struct {
-		Key     string `json:"key"`
-		Type    string `json:"type"`
-		Default any    `json:"default"`
-	} `json:"options,omitempty" yaml:"options,omitempty"`
-}
+type Collector = infrasonar.Collector
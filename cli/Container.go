@@ -1,15 +1,5 @@
 package cli
 
-import "fmt"
+import "github.com/infrasonar/infrasonar-cli/infrasonar"
 
-type Container struct {
-	Id   int    `json:"id" yaml:"id"`
-	Name string `json:"name" yaml:"name"`
-}
-
-func (c *Container) Str() string {
-	if c.Name == "" {
-		return fmt.Sprintf("%d", c.Id)
-	}
-	return c.Name
-}
+type Container = infrasonar.Container
@@ -1,20 +1,8 @@
 package cli
 
-import "fmt"
+import "github.com/infrasonar/infrasonar-cli/infrasonar"
 
-type Label struct {
-	Id          int    `json:"id" yaml:"id"`
-	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
-	Color       string `json:"color,omitempty" yaml:"color,omitempty"`
-	Description string `json:"description,omitempty" yaml:"description,omitempty"`
-}
-
-func (label *Label) Str() string {
-	if label.Name == "" {
-		return fmt.Sprintf("%d", label.Id)
-	}
-	return label.Name
-}
+type Label = infrasonar.Label
 
 var DefaultColor = "Steel"
 
@@ -35,6 +35,7 @@ func (m *LabelMap) Append(label *Label) {
 	for exists {
 		nn = fmt.Sprintf("%s_%d", name, i)
 		_, exists = m.labels[nn]
+		i += 1
 	}
 	m.labels[nn] = label
 	m.reverse[label.Id] = nn
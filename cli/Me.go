@@ -5,17 +5,15 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+
+	"github.com/infrasonar/infrasonar-cli/infrasonar"
 )
 
-type Me struct {
-	Permissions *[]string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
-	TokenType   string    `json:"tokenType,omitempty" yaml:"tokenType,omitempty"`
-}
+type Me = infrasonar.Me
 
-func (m *Me) CheckApplyPermissions() error {
-	if m.Permissions == nil {
-		return errors.New("permissions missing")
-	}
+// MissingApplyPermissions returns the permissions which are required for
+// apply but not granted to the token.
+func MissingApplyPermissions(m *Me) []string {
 	missing := []string{}
 	for _, required := range []string{
 		"API",
@@ -25,11 +23,18 @@ func (m *Me) CheckApplyPermissions() error {
 		"CONTAINER_MANAGEMENT",
 		"READ",
 	} {
-		if !slices.Contains(*m.Permissions, required) {
+		if m.Permissions == nil || !slices.Contains(*m.Permissions, required) {
 			missing = append(missing, required)
 		}
 	}
-	if len(missing) > 0 {
+	return missing
+}
+
+func CheckApplyPermissions(m *Me) error {
+	if m.Permissions == nil {
+		return errors.New("permissions missing")
+	}
+	if missing := MissingApplyPermissions(m); len(missing) > 0 {
 		return fmt.Errorf("token is missing the following permissions:\n\n- %s", strings.Join(missing, "\n- "))
 	}
 	return nil
@@ -1,11 +1,11 @@
 package cli
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"path"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -50,25 +50,6 @@ func StateFromFile(fn string) (*State, error) {
 	return &state, nil
 }
 
-func StateFromCache(containerId int) *State {
-	cliPath, err := CliPath()
-	if err != nil {
-		return nil
-	}
-	fn := path.Join(cliPath, fmt.Sprintf("cache_%09d.json", containerId))
-	state, _ := StateFromFile(fn)
-	return state
-}
-
-func (s *State) ClearCache() {
-	cliPath, err := CliPath()
-	if err != nil {
-		return
-	}
-	fn := path.Join(cliPath, fmt.Sprintf("cache_%09d.json", s.Container.Id))
-	os.Remove(fn)
-}
-
 func (s *State) makeLabelMap() {
 	lm := NewLabelMap()
 	for key, label := range s.Labels {
@@ -124,21 +105,15 @@ func (s *State) GetAge() (*time.Duration, error) {
 	return s.Info.GetAge()
 }
 
-func (s *State) WriteCache() {
-	cliPath, err := CliPath()
-	if err != nil {
-		return
-	}
-	fn := path.Join(cliPath, fmt.Sprintf("cache_%09d.json", s.Container.Id))
-	fp, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY, 0644)
+// Fingerprint returns a hash of the state, excluding the time info.
+func (s *State) Fingerprint() (string, error) {
+	clone := *s
+	clone.Info = nil
+	data, err := json.Marshal(&clone)
 	if err != nil {
-		return
-	}
-	defer fp.Close()
-
-	if out, err := json.Marshal(s); err == nil {
-		fmt.Fprintln(fp, string(out[:]))
+		return "", err
 	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
 }
 
 func (s *State) HasCollector() bool {
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCacheTtl is the maximum age of a cached state which is offered for
+// reuse.
+const DefaultCacheTtl = 8 * time.Hour
+
+// Cache files are named cache_<api host>_<container ID>.json, older versions
+// used cache_<container ID>.json.
+var (
+	reCacheFn       = regexp.MustCompile(`^cache_(.+)_(\d{9})\.json$`)
+	reLegacyCacheFn = regexp.MustCompile(`^cache_(\d{9})\.json$`)
+	reHostUnsafe    = regexp.MustCompile(`[^a-zA-Z0-9.\-]`)
+)
+
+// Cache is a cached container state on disk.
+type Cache struct {
+	Host        string // Empty for a cache file of an older version
+	ContainerId int
+	Fn          string
+	ModTime     time.Time
+	Size        int64
+}
+
+// FileHost returns the API host as used in the names of cache and journal
+// files.
+func FileHost(api string) string {
+	u, err := url.Parse(api)
+	if err != nil || u.Host == "" {
+		return "default"
+	}
+	return reHostUnsafe.ReplaceAllString(strings.ToLower(u.Host), "_")
+}
+
+func cacheFn(api string, containerId int) (string, error) {
+	cliPath, err := CliPath()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cliPath, fmt.Sprintf("cache_%s_%09d.json", FileHost(api), containerId)), nil
+}
+
+func StateFromCache(api string, containerId int) *State {
+	fn, err := cacheFn(api, containerId)
+	if err != nil {
+		return nil
+	}
+	state, _ := StateFromFile(fn)
+	return state
+}
+
+// ClearCache removes the cache for a container.
+func ClearCache(api string, containerId int) {
+	if fn, err := cacheFn(api, containerId); err == nil {
+		os.Remove(fn)
+	}
+}
+
+func (s *State) ClearCache(api string) {
+	ClearCache(api, s.Container.Id)
+}
+
+// WriteCache writes the state to the cache. The state is written to a
+// temporary file first, so a reader never sees a partial cache.
+func (s *State) WriteCache(api string) error {
+	fn, err := cacheFn(api, s.Container.Id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteFileAtomic(fn, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache '%s': %s", fn, err)
+	}
+	return nil
+}
+
+// ListCache returns all cache files, ordered by host and container ID.
+func ListCache() ([]*Cache, error) {
+	cliPath, err := CliPath()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(cliPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Cache{}, nil
+		}
+		return nil, err
+	}
+
+	caches := []*Cache{}
+	for _, entry := range entries {
+		var host, cid string
+		if m := reCacheFn.FindStringSubmatch(entry.Name()); m != nil {
+			host, cid = m[1], m[2]
+		} else if m := reLegacyCacheFn.FindStringSubmatch(entry.Name()); m != nil {
+			cid = m[1]
+		} else {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		containerId, _ := strconv.Atoi(cid)
+		caches = append(caches, &Cache{
+			Host:        host,
+			ContainerId: containerId,
+			Fn:          path.Join(cliPath, entry.Name()),
+			ModTime:     info.ModTime(),
+			Size:        info.Size(),
+		})
+	}
+	slices.SortFunc(caches, func(a, b *Cache) int {
+		if c := strings.Compare(a.Host, b.Host); c != 0 {
+			return c
+		}
+		return a.ContainerId - b.ContainerId
+	})
+	return caches, nil
+}
+
+// Matches returns true if the cache is for the given API; a cache of an
+// older version matches any API.
+func (c *Cache) Matches(api string) bool {
+	return c.Host == "" || c.Host == FileHost(api)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -14,6 +15,14 @@ var CollectorProperties = []string{"key", "name", "kind", "info", "minVersion",
 var MeProperties = []string{"permissions", "tokenType"}
 var cliPath string
 
+// SecretKeys are collector configuration keys with a value which must never
+// be shown.
+var SecretKeys = []string{"password", "secret"}
+
+func IsSecretKey(k string) bool {
+	return slices.Contains(SecretKeys, k)
+}
+
 type IntSet map[int]struct{}
 
 func (s IntSet) Set(k int) {
@@ -47,15 +56,20 @@ func GetJsonOrYaml(fn string) (string, error) {
 	return "", errors.New("expecting a .json or .yml/.yaml file")
 }
 
+// CliPath returns the directory for configurations and cache files. The
+// directory is ~/.infrasonar_cli, unless INFRASONAR_CONFIG_DIR is set.
 func CliPath() (string, error) {
 	if cliPath == "" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("failed to read home path: %s", err)
+		if dir := os.Getenv("INFRASONAR_CONFIG_DIR"); dir != "" {
+			cliPath = dir
+		} else {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("failed to read home path: %s", err)
+			}
+			cliPath = path.Join(homeDir, ".infrasonar_cli")
 		}
-
-		cliPath = path.Join(homeDir, ".infrasonar_cli")
-		err = os.MkdirAll(cliPath, os.ModePerm)
+		err := os.MkdirAll(cliPath, os.ModePerm)
 		if err != nil {
 			return "", fmt.Errorf("failed to make directory '%s': %s", cliPath, err)
 		}
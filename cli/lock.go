@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked is returned when a lock is held by another process and the
+// caller does not want to wait.
+var ErrLocked = errors.New("locked by another process")
+
+// ErrChanged is returned when a file was changed by another process after it
+// was read.
+var ErrChanged = errors.New("changed by another process")
+
+// FileLock is an advisory lock on a file. The lock is released by Unlock or
+// when the process exits.
+type FileLock struct {
+	fp *os.File
+}
+
+// LockFile locks a file, the file is created when it does not exist. An
+// exclusive lock excludes all other locks, a shared lock only excludes an
+// exclusive lock. Without wait, ErrLocked is returned when the file is
+// locked by another process.
+func LockFile(fn string, exclusive, wait bool) (*FileLock, error) {
+	fp, err := os.OpenFile(fn, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file '%s': %s", fn, err)
+	}
+	if err := lockFile(fp, exclusive, wait); err != nil {
+		fp.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to lock '%s': %s", fn, err)
+	}
+	return &FileLock{fp: fp}, nil
+}
+
+func (l *FileLock) Unlock() error {
+	err := unlockFile(l.fp)
+	if closeErr := l.fp.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WriteFileAtomic writes data to a temporary file which replaces fn, so a
+// reader never sees a partially written file.
+func WriteFileAtomic(fn string, data []byte, perm os.FileMode) error {
+	fp, err := os.CreateTemp(filepath.Dir(fn), filepath.Base(fn)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := fp.Name()
+	_, err = fp.Write(data)
+	if err == nil {
+		err = fp.Chmod(perm)
+	}
+	if err == nil {
+		err = fp.Sync()
+	}
+	if closeErr := fp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, fn)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package conf
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -12,6 +13,9 @@ import (
 )
 
 var configurationsFn string
+
+// content of the configurations file as read by Initialize
+var content []byte
 var conf = Configurations{}
 
 func Def() *Config {
@@ -25,7 +29,7 @@ func EnsureConfig(name string) *Config {
 	if name == "" {
 		config := Def()
 		if config == nil {
-			fmt.Fprintf(os.Stderr, "It appears no configuration has been set up.\nYou can create a new configuration using this command:\n\n  %s config new\n\n", filepath.Base(os.Args[0]))
+			fmt.Fprintf(os.Stderr, "It appears no configuration has been set up.\nYou can create a new configuration using this command:\n\n  %s config new\n\nOr set the token using the %s environment variable.\n\n", filepath.Base(os.Args[0]), EnvToken)
 			os.Exit(1)
 		}
 		return config
@@ -79,20 +83,39 @@ func New(name, token, api, output string) (*Config, error) {
 		return nil, err
 	}
 
-	// Append the config
+	// Append the config; it is stored by the next Write
 	conf.Configs = append(conf.Configs, &config)
-
-	// Write configurations to disk
-	err := Write()
-	return &config, err
+	return &config, nil
 }
 
+// Write replaces the configurations file. It fails when the file was changed
+// by another process after it was read by Initialize.
 func Write() error {
 	data, err := yaml.Marshal(conf)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configurationsFn, data, 0644)
+
+	lock, err := cli.LockFile(configurationsFn+".lock", true, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	current, err := os.ReadFile(configurationsFn)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read '%s': %s", configurationsFn, err)
+	}
+	if !bytes.Equal(current, content) {
+		return fmt.Errorf("'%s' was %w; please try again", configurationsFn, cli.ErrChanged)
+	}
+
+	// The file contains the tokens, so only the owner may read it
+	if err := cli.WriteFileAtomic(configurationsFn, data, 0600); err != nil {
+		return fmt.Errorf("failed to write '%s': %s", configurationsFn, err)
+	}
+	content = data
+	return nil
 }
 
 func GetConfigs() []*Config {
@@ -110,7 +133,14 @@ func Initialize() error {
 		return nil // success, just no configuration yet
 	}
 
-	content, err := os.ReadFile(configurationsFn)
+	warnReadable(configurationsFn)
+	lock, err := cli.LockFile(configurationsFn+".lock", false, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	content, err = os.ReadFile(configurationsFn)
 	if err != nil {
 		return fmt.Errorf("failed to read '%s': %s", configurationsFn, err)
 	}
@@ -3,21 +3,91 @@ package conf
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/req"
 )
 
 type Config struct {
-	Name     string `yaml:"name"`
-	EncToken string `yaml:"token"`
-	Api      string `yaml:"api"`
-	Output   string `yaml:"output"`
+	Name      string  `yaml:"name"`
+	EncToken  string  `yaml:"token"`
+	Api       string  `yaml:"api"`
+	Output    string  `yaml:"output"`
+	Retries   *int    `yaml:"retries,omitempty"`   // Default req.DefaultRetries
+	Timeout   *int    `yaml:"timeout,omitempty"`   // In seconds, 0 for no timeout
+	RateLimit float64 `yaml:"rateLimit,omitempty"` // Requests per second, 0 for no limit
+	Container int     `yaml:"container,omitempty"` // Default container ID
+	CacheTtl  *int    `yaml:"cacheTtl,omitempty"`  // In minutes, 0 disables the cache
+
+	Proxy              string `yaml:"proxy,omitempty"`
+	CaBundle           string `yaml:"caBundle,omitempty"`
+	ClientCert         string `yaml:"clientCert,omitempty"`
+	ClientKey          string `yaml:"clientKey,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty"`
+
+	token string // Token from the environment or a token file, see Resolve
+}
+
+// RequestSettings returns the settings for API requests made with this
+// configuration.
+func (c *Config) RequestSettings() req.Settings {
+	s := req.Settings{
+		Retries:   req.DefaultRetries,
+		Timeout:   req.DefaultTimeout,
+		RateLimit: c.RateLimit,
+
+		Proxy:              c.Proxy,
+		CaBundle:           c.CaBundle,
+		ClientCert:         c.ClientCert,
+		ClientKey:          c.ClientKey,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.Retries != nil {
+		s.Retries = *c.Retries
+	}
+	if c.Timeout != nil {
+		s.Timeout = time.Duration(*c.Timeout) * time.Second
+	}
+	return s
+}
+
+// GetCacheTtl returns the maximum age of a cached container state which may
+// be reused.
+func (c *Config) GetCacheTtl() time.Duration {
+	if c.CacheTtl == nil {
+		return cli.DefaultCacheTtl
+	}
+	return time.Duration(*c.CacheTtl) * time.Minute
+}
+
+// ContainerOrDefault returns the given container or, when empty, the default
+// container ID of the configuration.
+func (c *Config) ContainerOrDefault(container string) string {
+	if container == "" && c.Container != 0 {
+		return strconv.Itoa(c.Container)
+	}
+	return container
 }
 
 func (c *Config) GetToken() (string, error) {
-	return decryptAES(c.EncToken)
+	if c.token != "" {
+		return c.token, nil
+	}
+	key, err := getKey()
+	if err != nil {
+		return "", err
+	}
+	return decryptAES(key, c.EncToken)
 }
 
 func (c *Config) SetToken(token string) error {
-	encToken, err := encryptAES(token)
+	key, err := getKey()
+	if err != nil {
+		return err
+	}
+	encToken, err := encryptAES(key, token)
 	if err != nil {
 		return err
 	}
@@ -26,7 +96,7 @@ func (c *Config) SetToken(token string) error {
 }
 
 func (c *Config) EnsureToken() string {
-	token, err := decryptAES(c.EncToken)
+	token, err := c.GetToken()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to read token from configuration '%s' (error: %s)\n", c.Name, err)
 		os.Exit(1)
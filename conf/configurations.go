@@ -1,7 +1,8 @@
 package conf
 
 type Configurations struct {
-	Configs []*Config `yaml:"configs"`
+	Encryption *Encryption `yaml:"encryption,omitempty"`
+	Configs    []*Config   `yaml:"configs"`
 }
 
 func (c *Configurations) get(name string) *Config {
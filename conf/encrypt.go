@@ -5,14 +5,16 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
-	"fmt"
+	"errors"
 	"io"
 )
 
+// cipherKey is the builtin key. It is part of the public source code and
+// only obfuscates the tokens, see Encryption for a real key.
 var cipherKey = []byte("AmH0lGOt7S07N0QrUwgMKjXNC0dxcJPZ")
 
-func encryptAES(text string) (string, error) {
-	c, err := aes.NewCipher(cipherKey)
+func encryptAES(key []byte, text string) (string, error) {
+	c, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
@@ -32,13 +34,13 @@ func encryptAES(text string) (string, error) {
 	return str, nil
 }
 
-func decryptAES(b64 string) (string, error) {
+func decryptAES(key []byte, b64 string) (string, error) {
 	ct, err := base64.StdEncoding.DecodeString(b64)
 	if err != nil {
 		return "", err
 	}
 
-	c, err := aes.NewCipher(cipherKey)
+	c, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
@@ -50,7 +52,7 @@ func decryptAES(b64 string) (string, error) {
 
 	nonceSize := gcm.NonceSize()
 	if len(ct) < nonceSize {
-		fmt.Println(err)
+		return "", errors.New("invalid encrypted value")
 	}
 
 	nonce, ct := ct[:nonceSize], ct[nonceSize:]
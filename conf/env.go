@@ -0,0 +1,82 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/infrasonar/infrasonar-cli/infrasonar"
+	"github.com/infrasonar/infrasonar-cli/re"
+)
+
+// Environment variables which override the configuration file. The
+// directory with the configuration file can be set with
+// INFRASONAR_CONFIG_DIR, see cli.CliPath().
+const (
+	EnvToken  = "INFRASONAR_TOKEN"
+	EnvApi    = "INFRASONAR_API"
+	EnvConfig = "INFRASONAR_CONFIG"
+	EnvOutput = "INFRASONAR_OUTPUT"
+)
+
+// envConfigName is used when the token is given but no configuration
+// exists.
+const envConfigName = "environment"
+
+func exitEnv(format string, a ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	os.Exit(1)
+}
+
+// Resolve returns the configuration for a command. Each setting is taken
+// from the command-line flag, the environment or the configuration file, in
+// this order. The returned configuration is a copy and must not be written.
+func Resolve(name, tokenFile string) *Config {
+	if name == "" {
+		name = os.Getenv(EnvConfig)
+	}
+
+	var config Config
+	if name == "" && Def() == nil && (tokenFile != "" || os.Getenv(EnvToken) != "") {
+		// No configuration file is required when a token is given
+		config = Config{
+			Name:   envConfigName,
+			Api:    infrasonar.DefaultBaseURL,
+			Output: "yaml",
+		}
+	} else {
+		config = *EnsureConfig(name)
+	}
+
+	if api := os.Getenv(EnvApi); api != "" {
+		if !re.IsUrl.MatchString(api) {
+			exitEnv("Invalid API URL in %s", EnvApi)
+		}
+		config.Api = api
+	}
+	if output := os.Getenv(EnvOutput); output != "" {
+		switch output {
+		case "json", "yaml", "simple":
+		default:
+			exitEnv("Unknown output '%s' in %s {yaml,json,simple}", output, EnvOutput)
+		}
+		config.Output = output
+	}
+
+	if tokenFile != "" {
+		content, err := os.ReadFile(tokenFile)
+		if err != nil {
+			exitEnv("Failed to read token file '%s' (error: %s)", tokenFile, err)
+		}
+		config.token = strings.TrimSpace(string(content))
+		if !re.Token.MatchString(config.token) {
+			exitEnv("Invalid token in token file '%s'", tokenFile)
+		}
+	} else if token := os.Getenv(EnvToken); token != "" {
+		if !re.Token.MatchString(token) {
+			exitEnv("Invalid token in %s", EnvToken)
+		}
+		config.token = token
+	}
+	return &config
+}
@@ -0,0 +1,244 @@
+package conf
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/howeyc/gopass"
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KeyBuiltin    = "builtin"    // Compiled-in key, only obfuscates the tokens
+	KeyPassphrase = "passphrase" // Key derived from a passphrase
+	KeyFile       = "key-file"   // Random key stored in a file
+)
+
+// EnvPassphrase can be used to provide the passphrase non-interactively.
+const EnvPassphrase = "INFRASONAR_PASSPHRASE"
+
+// EnvNewPassphrase can be used to provide the new passphrase for Rekey
+// non-interactively.
+const EnvNewPassphrase = "INFRASONAR_NEW_PASSPHRASE"
+
+const (
+	keySize     = 32
+	checkText   = "infrasonar"
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+	scryptSalts = 16
+)
+
+// Encryption describes how the tokens are encrypted. When not set, the
+// builtin key is used.
+type Encryption struct {
+	Method  string `yaml:"method"`
+	Salt    string `yaml:"salt,omitempty"`    // Base64, for a passphrase
+	KeyFile string `yaml:"keyFile,omitempty"` // For a key file
+	Check   string `yaml:"check"`             // Encrypted check text to verify the key
+}
+
+// key is derived when a token is first encrypted or decrypted.
+var key []byte
+
+func (e *Encryption) method() string {
+	if e == nil {
+		return KeyBuiltin
+	}
+	return e.Method
+}
+
+func getKey() ([]byte, error) {
+	if key != nil {
+		return key, nil
+	}
+	enc := conf.Encryption
+	var k []byte
+	var err error
+	switch enc.method() {
+	case KeyBuiltin:
+		return cipherKey, nil
+	case KeyPassphrase:
+		var passphrase string
+		passphrase, err = readPassphrase("Passphrase: ", EnvPassphrase)
+		if err == nil {
+			k, err = deriveKey(passphrase, enc.Salt)
+		}
+	case KeyFile:
+		k, err = readKeyFile(enc.KeyFile)
+	default:
+		return nil, fmt.Errorf("unknown encryption method '%s' in '%s'", enc.Method, configurationsFn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if check, err := decryptAES(k, enc.Check); err != nil || check != checkText {
+		if enc.Method == KeyPassphrase {
+			return nil, errors.New("invalid passphrase")
+		}
+		return nil, fmt.Errorf("key file '%s' does not match the configurations", enc.KeyFile)
+	}
+	key = k
+	return key, nil
+}
+
+func readPassphrase(prompt, env string) (string, error) {
+	if passphrase := os.Getenv(env); passphrase != "" {
+		return passphrase, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	pass, err := gopass.GetPasswdMasked()
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase (%s); use the %s environment variable to run non-interactively", err, env)
+	}
+	return string(pass), nil
+}
+
+func deriveKey(passphrase, salt string) ([]byte, error) {
+	s, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt in '%s'", configurationsFn)
+	}
+	return scrypt.Key([]byte(passphrase), s, scryptN, scryptR, scryptP, keySize)
+}
+
+func readKeyFile(fn string) ([]byte, error) {
+	content, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file '%s': %s", fn, err)
+	}
+	k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(k) != keySize {
+		return nil, fmt.Errorf("invalid key file '%s'; expecting %d base64 encoded bytes", fn, keySize)
+	}
+	warnReadable(fn)
+	return k, nil
+}
+
+// checkKeyFileDir returns an error when the key file is in the configuration
+// directory, otherwise the key is copied along with the tokens.
+func checkKeyFileDir(fn string) error {
+	cliPath, err := cli.CliPath()
+	if err != nil {
+		return err
+	}
+	dir, _ := filepath.Abs(cliPath)
+	if rel, err := filepath.Rel(dir, fn); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("the key file must be outside '%s'", dir)
+	}
+	return nil
+}
+
+// createKeyFile writes a new random key.
+func createKeyFile(fn string) ([]byte, error) {
+	k := make([]byte, keySize)
+	if _, err := rand.Read(k); err != nil {
+		return nil, err
+	}
+	fp, err := os.OpenFile(fn, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key file '%s': %s", fn, err)
+	}
+	defer fp.Close()
+	if _, err := fmt.Fprintln(fp, base64.StdEncoding.EncodeToString(k)); err != nil {
+		return nil, fmt.Errorf("failed to write key file '%s': %s", fn, err)
+	}
+	return k, nil
+}
+
+// warnReadable writes a warning if a file is readable by other users.
+func warnReadable(fn string) {
+	if fi, err := os.Stat(fn); err == nil && fi.Mode().Perm()&0004 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: '%s' is readable by other users; restrict access with: chmod 600 %s\n", fn, fn)
+	}
+}
+
+// Rekey encrypts all tokens with a new key. For a key file, an existing
+// file is used or a new file is created.
+func Rekey(method, keyFile string) error {
+	tokens := make([]string, len(conf.Configs))
+	for i, config := range conf.Configs {
+		token, err := config.GetToken()
+		if err != nil {
+			return fmt.Errorf("failed to read token from configuration '%s' (error: %s)", config.Name, err)
+		}
+		tokens[i] = token
+	}
+
+	var enc *Encryption
+	var k []byte
+	switch method {
+	case KeyBuiltin:
+		k = cipherKey
+	case KeyPassphrase:
+		passphrase, err := readPassphrase("New passphrase: ", EnvNewPassphrase)
+		if err != nil {
+			return err
+		}
+		if os.Getenv(EnvNewPassphrase) == "" {
+			again, err := readPassphrase("Repeat passphrase: ", EnvNewPassphrase)
+			if err != nil {
+				return err
+			}
+			if again != passphrase {
+				return errors.New("passphrases do not match")
+			}
+		}
+		if passphrase == "" {
+			return errors.New("empty passphrase")
+		}
+		salt := make([]byte, scryptSalts)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		enc = &Encryption{Method: KeyPassphrase, Salt: base64.StdEncoding.EncodeToString(salt)}
+		if k, err = deriveKey(passphrase, enc.Salt); err != nil {
+			return err
+		}
+	case KeyFile:
+		fn, err := filepath.Abs(keyFile)
+		if err != nil || keyFile == "" {
+			return errors.New("a key file is required")
+		}
+		if err = checkKeyFileDir(fn); err != nil {
+			return err
+		}
+		if _, err = os.Stat(fn); err == nil {
+			k, err = readKeyFile(fn)
+		} else {
+			k, err = createKeyFile(fn)
+		}
+		if err != nil {
+			return err
+		}
+		enc = &Encryption{Method: KeyFile, KeyFile: fn}
+	default:
+		return fmt.Errorf("unknown encryption method '%s'", method)
+	}
+
+	if enc != nil {
+		check, err := encryptAES(k, checkText)
+		if err != nil {
+			return err
+		}
+		enc.Check = check
+	}
+
+	for i, config := range conf.Configs {
+		encToken, err := encryptAES(k, tokens[i])
+		if err != nil {
+			return err
+		}
+		config.EncToken = encToken
+	}
+	conf.Encryption = enc
+	key = k
+	return Write()
+}
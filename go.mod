@@ -6,13 +6,13 @@ require (
 	github.com/akamensky/argparse v1.4.0
 	github.com/fatih/color v1.18.0
 	github.com/howeyc/gopass v0.0.0-20210920133722-c8aef6fb66ef
+	golang.org/x/crypto v0.32.0
+	golang.org/x/sys v0.29.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/crypto v0.32.0 // indirect
-	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/term v0.28.0 // indirect
 )
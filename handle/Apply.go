@@ -2,22 +2,95 @@ package handle
 
 import (
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/infrasonar/infrasonar-cli/cli"
 	"github.com/infrasonar/infrasonar-cli/handle/util"
+	"github.com/infrasonar/infrasonar-cli/infrasonar"
 	"github.com/infrasonar/infrasonar-cli/req"
 )
 
+type TApply struct {
+	Api         string
+	Token       string
+	Filename    string
+	DryRun      bool
+	Purge       bool
+	PurgeAssets bool
+	PurgeLabels bool
+	PlanFormat  string
+	PlanOut     string
+	PlanIn      string
+	Atomic      bool
+	Resume      bool
+	Parallel    int
+	CacheTtl    time.Duration
+	MatchBy     string // Match assets without an ID by name or property:<key>
+	WriteBack   bool
+
+	// Answers for non-interactive use; nil means the question will be asked
+	Yes                bool
+	ConfirmDestructive bool
+	UseCache           *bool
+	RemoteValidate     *bool
+	ShowDetails        *bool
+
+	wb *writeBack // Set when WriteBack is used
+}
+
+var yes = true
+
+// writeIds writes the IDs of new and matched assets and labels to the input
+// file when --write-back is used.
+func (cmd *TApply) writeIds() {
+	if cmd.wb == nil || cmd.DryRun {
+		return
+	}
+	n, err := cmd.wb.write()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write IDs to the input file: %s\n", err)
+		return
+	}
+	if n > 0 {
+		fmt.Printf("Wrote %d ID%s to: %s\n", n, util.Plural(n), cmd.Filename)
+	}
+}
+
+// answer returns the answer for a question, or nil if it must be asked.
+func (cmd *TApply) answer(a *bool) *bool {
+	if a == nil && cmd.Yes {
+		return &yes
+	}
+	return a
+}
+
+// confirmDestructive returns the answer for the confirmation of destructive
+// changes. This question is not answered by --yes, only by
+// --confirm-destructive.
+func (cmd *TApply) confirmDestructive(n int) *bool {
+	if cmd.ConfirmDestructive {
+		return &yes
+	}
+	if cmd.Yes && !util.IsTerminal() {
+		util.ExitInputErr("Found %d destructive change%s. Use --confirm-destructive to apply destructive changes non-interactively.", n, util.Plural(n))
+	}
+	return nil
+}
+
 type Change struct {
-	info string
-	task any
+	info        string
+	task        any
+	destructive bool
 }
 
 type TaskUpsertZone struct {
 	zone *cli.Zone
+	old  *cli.Zone
 }
 
 type TaskSetCollectorDisplay struct {
@@ -29,24 +102,33 @@ type TaskCreateAsset struct {
 	asset *cli.AssetCli
 }
 
+type TaskDeleteAsset struct {
+	asset *cli.AssetCli
+}
+
 type TaskSetAssetName struct {
 	asset *cli.AssetCli
+	old   string
 }
 
 type TaskSetAssetMode struct {
 	asset *cli.AssetCli
+	old   string
 }
 
 type TaskSetAssetKind struct {
 	asset *cli.AssetCli
+	old   string
 }
 
 type TaskSetAssetZone struct {
 	asset *cli.AssetCli
+	old   *int
 }
 
 type TaskSetAssetDescription struct {
 	asset *cli.AssetCli
+	old   string
 }
 
 type TaskAddLabelToAsset struct {
@@ -75,81 +157,122 @@ type TaskUpsertCollectorToAsset struct {
 	asset        *cli.AssetCli
 	collectorKey string
 	config       map[string]any
+	old          *cli.TCollector
 }
 
 type TaskRemoveCollectorFromAsset struct {
 	asset        *cli.AssetCli
 	collectorKey string
+	old          map[string]any
 }
 
 type TaskCreateLabel struct {
 	label *cli.Label
 }
 
+type TaskDeleteLabel struct {
+	label *cli.Label
+}
+
 type TaskSetLabelName struct {
 	label *cli.Label
+	old   string
 }
 
 type TaskSetLabelColor struct {
 	label *cli.Label
+	old   string
 }
 
 type TaskSetLabelDescription struct {
 	label *cli.Label
+	old   string
 }
 
-func processChanges(api, token string, containerId int, changes *[]*Change) {
-	n := len(*changes)
-	for i, c := range *changes {
-		fmt.Printf("Processing task %d/%d: %s ...\n", i+1, n, c.info)
-		var err error
-		switch task := c.task.(type) {
-		case TaskUpsertZone:
-			err = req.UpsertZone(api, token, containerId, task.zone.Zone, task.zone.Name)
-		case TaskSetCollectorDisplay:
-			err = req.SetCollectorDisplay(api, token, containerId, task.collectorKey, task.display)
-		case TaskCreateAsset:
-			task.asset.Id, err = req.CreateAsset(api, token, containerId, task.asset.Name)
-		case TaskSetAssetName:
-			err = req.SetAssetName(api, token, task.asset.Id, task.asset.Name)
-		case TaskSetAssetMode:
-			err = req.SetAssetMode(api, token, task.asset.Id, task.asset.Mode, nil)
-		case TaskSetAssetKind:
-			err = req.SetAssetKind(api, token, task.asset.Id, task.asset.Kind)
-		case TaskSetAssetZone:
-			err = req.SetAssetZone(api, token, task.asset.Id, *task.asset.Zone)
-		case TaskSetAssetDescription:
-			err = req.SetAssetDescription(api, token, task.asset.Id, task.asset.Description)
-		case TaskAddLabelToAsset:
-			err = req.AddLabelToAsset(api, token, task.asset.Id, task.label.Id)
-		case TaskDeleteLabelFromAsset:
-			err = req.DeleteLabelFromAsset(api, token, task.asset.Id, task.label.Id)
-		case TaskEnableAssetCheck:
-			err = req.EnableAssetCheck(api, token, task.asset.Id, task.collectorKey, task.checkKey)
-		case TaskDisableAssetCheck:
-			err = req.DisableAssetCheck(api, token, task.asset.Id, task.collectorKey, task.checkKey)
-		case TaskUpsertCollectorToAsset:
-			err = req.UpsertCollectorToAsset(api, token, task.asset.Id, task.collectorKey, task.config)
-		case TaskRemoveCollectorFromAsset:
-			err = req.RemoveCollectorFromAsset(api, token, task.asset.Id, task.collectorKey)
-		case TaskCreateLabel:
-			task.label.Id, err = req.CreateLabel(api, token, containerId, task.label.Name)
-		case TaskSetLabelName:
-			err = req.SetLabelName(api, token, task.label.Id, task.label.Name)
-		case TaskSetLabelColor:
-			err = req.SetLabelColor(api, token, task.label.Id, task.label.Color)
-		case TaskSetLabelDescription:
-			err = req.SetLabelDescription(api, token, task.label.Id, task.label.Description)
-
-		}
-		util.ExitOnErr(err)
+func processTask(api, token string, containerId int, c *Change) error {
+	var err error
+	switch task := c.task.(type) {
+	case TaskUpsertZone:
+		err = req.UpsertZone(api, token, containerId, task.zone.Zone, task.zone.Name)
+	case TaskSetCollectorDisplay:
+		err = req.SetCollectorDisplay(api, token, containerId, task.collectorKey, task.display)
+	case TaskCreateAsset:
+		task.asset.Id, err = req.CreateAsset(api, token, containerId, task.asset.Name)
+	case TaskDeleteAsset:
+		err = req.DeleteAsset(api, token, task.asset.Id)
+	case TaskSetAssetName:
+		err = req.SetAssetName(api, token, task.asset.Id, task.asset.Name)
+	case TaskSetAssetMode:
+		err = req.SetAssetMode(api, token, task.asset.Id, task.asset.Mode, nil)
+	case TaskSetAssetKind:
+		err = req.SetAssetKind(api, token, task.asset.Id, task.asset.Kind)
+	case TaskSetAssetZone:
+		err = req.SetAssetZone(api, token, task.asset.Id, *task.asset.Zone)
+	case TaskSetAssetDescription:
+		err = req.SetAssetDescription(api, token, task.asset.Id, task.asset.Description)
+	case TaskAddLabelToAsset:
+		err = req.AddLabelToAsset(api, token, task.asset.Id, task.label.Id)
+	case TaskDeleteLabelFromAsset:
+		err = req.DeleteLabelFromAsset(api, token, task.asset.Id, task.label.Id)
+	case TaskEnableAssetCheck:
+		err = req.EnableAssetCheck(api, token, task.asset.Id, task.collectorKey, task.checkKey)
+	case TaskDisableAssetCheck:
+		err = req.DisableAssetCheck(api, token, task.asset.Id, task.collectorKey, task.checkKey)
+	case TaskUpsertCollectorToAsset:
+		err = req.UpsertCollectorToAsset(api, token, task.asset.Id, task.collectorKey, task.config)
+	case TaskRemoveCollectorFromAsset:
+		err = req.RemoveCollectorFromAsset(api, token, task.asset.Id, task.collectorKey)
+	case TaskCreateLabel:
+		task.label.Id, err = req.CreateLabel(api, token, containerId, task.label.Name)
+	case TaskDeleteLabel:
+		err = req.DeleteLabel(api, token, task.label.Id)
+	case TaskSetLabelName:
+		err = req.SetLabelName(api, token, task.label.Id, task.label.Name)
+	case TaskSetLabelColor:
+		err = req.SetLabelColor(api, token, task.label.Id, task.label.Color)
+	case TaskSetLabelDescription:
+		err = req.SetLabelDescription(api, token, task.label.Id, task.label.Description)
 	}
+	return err
 }
 
 func cval(a any) string {
 	return color.HiCyanString("%v", a)
 }
 
+// checkPresence exits when the asset or one of its items has an invalid
+// state.
+func checkPresence(ta *cli.AssetCli) {
+	if err := ta.Check(); err != nil {
+		util.ExitInputErr("Asset '%s' has an %s", ta.Str(), err)
+	}
+	if ta.Collectors != nil {
+		for _, c := range *ta.Collectors {
+			if err := c.Check(); err != nil {
+				util.ExitInputErr("Collector '%s' on asset '%s' has an %s", c.Key, ta.Str(), err)
+			}
+		}
+	}
+	if ta.DisabledChecks != nil {
+		for _, c := range *ta.DisabledChecks {
+			if err := c.Presence.Check(); err != nil {
+				util.ExitInputErr("Disabled check '%s/%s' on asset '%s' has an %s", c.Collector, c.Check, ta.Str(), err)
+			}
+		}
+	}
+	if ta.Properties != nil {
+		for _, p := range *ta.Properties {
+			if err := p.Check(); err != nil {
+				util.ExitInputErr("Property '%s' on asset '%s' has an %s", p.Key, ta.Str(), err)
+			}
+			if p.IsAbsent() {
+				// Properties are not applied, so removing one would be a no-op
+				util.ExitInputErr("Property '%s' on asset '%s' is marked as absent; removing properties is not supported.", p.Key, ta.Str())
+			}
+		}
+	}
+}
+
 func readLabelChanges(changes *[]*Change, cl, tl *cli.Label) {
 	if cl.Id != tl.Id {
 		panic("label ID mismatch")
@@ -157,19 +280,19 @@ func readLabelChanges(changes *[]*Change, cl, tl *cli.Label) {
 	if tl.Name != "" && cl.Name != "" && tl.Name != cl.Name {
 		*changes = append(*changes, &Change{
 			info: fmt.Sprintf("Set name for label ID %s to: %s", cval(tl.Id), cval(tl.Name)),
-			task: TaskSetLabelName{label: tl},
+			task: TaskSetLabelName{label: tl, old: cl.Name},
 		})
 	}
 	if tl.Color != "" && tl.Color != cl.Color {
 		*changes = append(*changes, &Change{
 			info: fmt.Sprintf("Set color for label ID %s to '%s'", cval(tl.Id), cval(tl.Color)),
-			task: TaskSetLabelColor{label: tl},
+			task: TaskSetLabelColor{label: tl, old: cl.Color},
 		})
 	}
 	if tl.Description != "" && tl.Description != cl.Description {
 		*changes = append(*changes, &Change{
 			info: fmt.Sprintf("Set description for label ID %s to '%s'", cval(tl.Id), cval(util.Short(tl.Description, 12))),
-			task: TaskSetLabelDescription{label: tl},
+			task: TaskSetLabelDescription{label: tl, old: cl.Description},
 		})
 	}
 }
@@ -178,31 +301,31 @@ func assetChanges(changes *[]*Change, purge bool, ca, ta *cli.AssetCli, cs, ts *
 	if ta.Name != "" && ca.Name != "" && ta.Name != ca.Name {
 		*changes = append(*changes, &Change{
 			info: fmt.Sprintf("Set name for asset '%s' to: '%s'", cval(ta.Str()), cval(ta.Name)),
-			task: TaskSetAssetName{asset: ta},
+			task: TaskSetAssetName{asset: ta, old: ca.Name},
 		})
 	}
 	if ta.Mode != "" && ta.Mode != ca.Mode {
 		*changes = append(*changes, &Change{
 			info: fmt.Sprintf("Set mode for asset '%s' to: '%s'", cval(ta.Str()), cval(ta.Mode)),
-			task: TaskSetAssetMode{asset: ta},
+			task: TaskSetAssetMode{asset: ta, old: ca.Mode},
 		})
 	}
 	if ta.Kind != "" && ta.Kind != ca.Kind {
 		*changes = append(*changes, &Change{
 			info: fmt.Sprintf("Set kind for asset '%s' to: '%s'", cval(ta.Str()), cval(ta.Kind)),
-			task: TaskSetAssetKind{asset: ta},
+			task: TaskSetAssetKind{asset: ta, old: ca.Kind},
 		})
 	}
 	if ta.Zone != nil && (ca.Zone == nil || *ta.Zone != *ca.Zone) {
 		*changes = append(*changes, &Change{
 			info: fmt.Sprintf("Set zone for asset '%s' to: %s", cval(ta.Str()), cval(*ta.Zone)),
-			task: TaskSetAssetZone{asset: ta},
+			task: TaskSetAssetZone{asset: ta, old: ca.Zone},
 		})
 	}
 	if ta.Description != "" && ta.Description != ca.Description {
 		*changes = append(*changes, &Change{
 			info: fmt.Sprintf("Set description for asset '%s' to: '%s'", cval(ta.Str()), cval(util.Short(ta.Description, 12))),
-			task: TaskSetAssetDescription{asset: ta},
+			task: TaskSetAssetDescription{asset: ta, old: ca.Description},
 		})
 	}
 	if ta.Labels != nil {
@@ -217,6 +340,16 @@ func assetChanges(changes *[]*Change, purge bool, ca, ta *cli.AssetCli, cs, ts *
 			}
 		}
 	}
+	for _, key := range ta.AbsentLabels {
+		if label := ts.LabelByKey(key); label != nil {
+			if ca.HasLabelId(label.Id, cs.GetLabelMap()) {
+				*changes = append(*changes, &Change{
+					info: fmt.Sprintf("Delete label '%s' from asset '%s'", cval(label.Str()), cval(ta.Str())),
+					task: TaskDeleteLabelFromAsset{asset: ta, label: label},
+				})
+			}
+		}
+	}
 	if ta.Collectors != nil {
 		for _, collector := range *ta.Collectors {
 			var other *cli.TCollector
@@ -230,7 +363,14 @@ func assetChanges(changes *[]*Change, purge bool, ca, ta *cli.AssetCli, cs, ts *
 				}
 			}
 
-			if other == nil {
+			if collector.IsAbsent() {
+				if other != nil {
+					*changes = append(*changes, &Change{
+						info: fmt.Sprintf("Remove collector '%s' from asset '%s'", cval(collector.Key), cval(ta.Str())),
+						task: TaskRemoveCollectorFromAsset{asset: ta, collectorKey: collector.Key, old: other.Config},
+					})
+				}
+			} else if other == nil {
 				*changes = append(*changes, &Change{
 					info: fmt.Sprintf("Add collector '%s' to asset '%s'", cval(collector.Key), cval(ta.Str())),
 					task: TaskUpsertCollectorToAsset{asset: ta, collectorKey: collector.Key, config: collector.Config},
@@ -241,7 +381,7 @@ func assetChanges(changes *[]*Change, purge bool, ca, ta *cli.AssetCli, cs, ts *
 					if !ok || !reflect.DeepEqual(v, ov) {
 						*changes = append(*changes, &Change{
 							info: fmt.Sprintf("Update collector '%s' configuration for asset '%s'", cval(collector.Key), cval(ta.Str())),
-							task: TaskUpsertCollectorToAsset{asset: ta, collectorKey: collector.Key, config: collector.Config},
+							task: TaskUpsertCollectorToAsset{asset: ta, collectorKey: collector.Key, config: collector.Config, old: other},
 						})
 						break
 					}
@@ -260,7 +400,14 @@ func assetChanges(changes *[]*Change, purge bool, ca, ta *cli.AssetCli, cs, ts *
 					}
 				}
 			}
-			if !found {
+			if disabledChk.IsAbsent() {
+				if found {
+					*changes = append(*changes, &Change{
+						info: fmt.Sprintf("Enable collector check '%s/%s' on asset '%s'", cval(disabledChk.Collector), cval(disabledChk.Check), cval(ta.Str())),
+						task: TaskEnableAssetCheck{asset: ta, collectorKey: disabledChk.Collector, checkKey: disabledChk.Check},
+					})
+				}
+			} else if !found {
 				*changes = append(*changes, &Change{
 					info: fmt.Sprintf("Disable collector check '%s/%s' on asset '%s'", cval(disabledChk.Collector), cval(disabledChk.Check), cval(ta.Str())),
 					task: TaskDisableAssetCheck{asset: ta, collectorKey: disabledChk.Collector, checkKey: disabledChk.Check},
@@ -268,12 +415,11 @@ func assetChanges(changes *[]*Change, purge bool, ca, ta *cli.AssetCli, cs, ts *
 			}
 		}
 	}
-
 	if purge {
 		if ca.Labels != nil && ta.Labels != nil {
 			for _, key := range *ca.Labels {
 				if label := cs.LabelByKey(key); label != nil {
-					if !ta.HasLabelId(label.Id, ts.GetLabelMap()) {
+					if !ta.HasLabelId(label.Id, ts.GetLabelMap()) && !ta.HasAbsentLabelId(label.Id, ts.GetLabelMap()) {
 						*changes = append(*changes, &Change{
 							info: fmt.Sprintf("Delete label '%s' from asset '%s'", cval(label.Str()), cval(ta.Str())),
 							task: TaskDeleteLabelFromAsset{asset: ta, label: label},
@@ -311,7 +457,7 @@ func assetChanges(changes *[]*Change, purge bool, ca, ta *cli.AssetCli, cs, ts *
 				if !found {
 					*changes = append(*changes, &Change{
 						info: fmt.Sprintf("Remove collector '%s' from asset '%s'", cval(collector.Key), cval(ta.Str())),
-						task: TaskRemoveCollectorFromAsset{asset: ta, collectorKey: collector.Key},
+						task: TaskRemoveCollectorFromAsset{asset: ta, collectorKey: collector.Key, old: collector.Config},
 					})
 				}
 			}
@@ -327,9 +473,9 @@ func ensureCollectors(ts *cli.State, cMap map[string]*cli.Collector) []*Change {
 	//
 	enableCollector := cli.StrSet{}
 	for _, ta := range ts.Assets {
-		if ta.Collectors != nil {
+		if ta.Collectors != nil && !ta.IsAbsent() {
 			for _, c := range *ta.Collectors {
-				if enableCollector.Has(c.Key) {
+				if c.IsAbsent() || enableCollector.Has(c.Key) {
 					continue
 				}
 				if _, ok := cMap[c.Key]; !ok {
@@ -345,25 +491,24 @@ func ensureCollectors(ts *cli.State, cMap map[string]*cli.Collector) []*Change {
 	return changes
 }
 
-func ensureChanges(api, token string, purge bool, cs, ts *cli.State, cMap map[string]*cli.Collector) []*Change {
+func ensureChanges(cmd *TApply, cs, ts *cli.State, cMap map[string]*cli.Collector) []*Change {
 	changes := []*Change{}
 	//
-	// Container changes
+	// Container changes; the API has no documented request to change a
+	// container, so a different name is only reported
 	//
-	// if ts.Container.Name != "" && ts.Container.Name != cs.Container.Name {
-	// 	changes = append(changes, &Change{
-	// 		info: fmt.Sprintf("Rename container ID %s from '%s' to '%s'", cval(cs.Container.Id), cval(cs.Container.Name), cval(ts.Container.Name)),
-	// 		// TODO: Set name container API
-	// 	})
-	// }
+	if ts.Container.Name != "" && ts.Container.Name != cs.Container.Name {
+		fmt.Fprintf(os.Stderr, "Warning: the container name '%s' in the input file differs from '%s' and is not applied.\n", ts.Container.Name, cs.Container.Name)
+	}
 	//
 	// Show collectors and sanity check mode and disabled checks
 	//
 	enableCollector := cli.StrSet{}
+	withProperties := 0
 	for _, ta := range ts.Assets {
-		if ta.Collectors != nil {
+		if ta.Collectors != nil && !ta.IsAbsent() {
 			for _, c := range *ta.Collectors {
-				if enableCollector.Has(c.Key) {
+				if c.IsAbsent() || enableCollector.Has(c.Key) {
 					continue
 				}
 				if _, ok := cMap[c.Key]; !ok {
@@ -377,25 +522,47 @@ func ensureChanges(api, token string, purge bool, cs, ts *cli.State, cMap map[st
 		}
 		if ta.DisabledChecks != nil {
 			for _, disabledChk := range *ta.DisabledChecks {
+				if disabledChk.IsAbsent() {
+					continue
+				}
 				found := false
 				if ta.Collectors != nil {
 					for _, c := range *ta.Collectors {
-						if c.Key == disabledChk.Collector {
+						if c.Key == disabledChk.Collector && !c.IsAbsent() {
 							found = true
 							break
 						}
 					}
 					if !found {
-						util.ExitErr("Collector '%s' is not configured for asset '%s', but a disabled check for it exists.", disabledChk.Collector, ta.Str())
+						util.ExitInputErr("Collector '%s' is not configured for asset '%s', but a disabled check for it exists.", disabledChk.Collector, ta.Str())
 					}
 				}
 			}
 		}
+		if ta.Properties != nil {
+			if len(*ta.Properties) > 0 {
+				withProperties += 1
+			}
+			seen := cli.StrSet{}
+			for _, property := range *ta.Properties {
+				if property.Key == "" {
+					util.ExitInputErr("Asset '%s' has a property without a key.", ta.Str())
+				}
+				if seen.Has(property.Key) {
+					util.ExitInputErr("Asset '%s' has property '%s' defined more than once.", ta.Str(), property.Key)
+				}
+				seen.Set(property.Key)
+			}
+		}
 		switch ta.Mode {
 		case "", "normal", "maintenance", "disabled":
 			continue
 		}
-		util.ExitErr("Asset '%s' has an invalid mode '%s'. Must be one of {normal,maintenance,disabled}", ta.Str(), ta.Mode)
+		util.ExitInputErr("Asset '%s' has an invalid mode '%s'. Must be one of {normal,maintenance,disabled}", ta.Str(), ta.Mode)
+	}
+	if withProperties > 0 {
+		// The API has no documented request to change asset properties
+		fmt.Fprintf(os.Stderr, "Warning: properties of %d asset%s are not applied; properties are only used by --match-by.\n", withProperties, util.Plural(withProperties))
 	}
 
 	//
@@ -405,10 +572,10 @@ func ensureChanges(api, token string, purge bool, cs, ts *cli.State, cMap map[st
 		cz := cs.ZoneById(tz.Zone)
 		if cz == nil {
 			if tz.Zone < 1 || tz.Zone > 9 {
-				util.ExitErr("Invalid zone '%d'. Must be a value between 1 and 9.", tz.Zone)
+				util.ExitInputErr("Invalid zone '%d'. Must be a value between 1 and 9.", tz.Zone)
 			}
 			if tz.Name == "" {
-				util.ExitErr("Zone '%d' is new and therefore requires a name", tz.Zone)
+				util.ExitInputErr("Zone '%d' is new and therefore requires a name", tz.Zone)
 			}
 			changes = append(changes, &Change{
 				info: fmt.Sprintf("Create new zone: %s", cval(tz.Str())),
@@ -418,7 +585,7 @@ func ensureChanges(api, token string, purge bool, cs, ts *cli.State, cMap map[st
 			if tz.Name != "" && tz.Name != cz.Name {
 				changes = append(changes, &Change{
 					info: fmt.Sprintf("Rename zone ID %s from '%s' to '%s'", cval(tz.Zone), cval(cz.Name), cval(tz.Name)),
-					task: TaskUpsertZone{zone: tz},
+					task: TaskUpsertZone{zone: tz, old: cz},
 				})
 			}
 		}
@@ -429,7 +596,7 @@ func ensureChanges(api, token string, purge bool, cs, ts *cli.State, cMap map[st
 		if tl.Id == 0 {
 			// New label
 			if tl.Name == "" {
-				util.ExitErr("One or more labels are missing both an 'id' and a 'name'. At least one of these attributes is required for each label.")
+				util.ExitInputErr("One or more labels are missing both an 'id' and a 'name'. At least one of these attributes is required for each label.")
 			}
 			changes = append(changes, &Change{
 				info: fmt.Sprintf("Create new label: %s", cval(tl.Name)),
@@ -447,7 +614,7 @@ func ensureChanges(api, token string, purge bool, cs, ts *cli.State, cMap map[st
 	}
 	if len(missingLabelIds) > 0 {
 		fmt.Println("Get missing labels...")
-		lm, err := req.GetLabels(api, token, missingLabelIds)
+		lm, err := req.GetLabels(cmd.Api, cmd.Token, missingLabelIds)
 		util.ExitOnErr(err)
 		for _, tl := range ts.Labels {
 			if tl.Id != 0 {
@@ -460,47 +627,168 @@ func ensureChanges(api, token string, purge bool, cs, ts *cli.State, cMap map[st
 		}
 	}
 	for _, ta := range ts.Assets {
+		if ta.IsAbsent() {
+			if ta.Id == 0 {
+				if cmd.MatchBy == "" {
+					util.ExitInputErr("Asset '%s' is marked as absent but has no 'id'. Add the asset ID or use --match-by to find the asset.", ta.Str())
+				}
+				fmt.Fprintf(os.Stderr, "Warning: asset '%s' is marked as absent but no matching asset is found; nothing to delete.\n", ta.Str())
+			}
+			continue // Deleted below when it exists
+		}
 		if ta.Labels != nil {
 			for _, labelKey := range *ta.Labels {
 				if _, ok := ts.Labels[labelKey]; !ok {
-					util.ExitErr("Asset '%s' is using label reference '%s' which does not exist in 'labels'.", ta.Str(), labelKey)
+					util.ExitInputErr("Asset '%s' is using label reference '%s' which does not exist in 'labels'.", ta.Str(), labelKey)
 				}
 			}
 		}
+		for _, labelKey := range ta.AbsentLabels {
+			if _, ok := ts.Labels[labelKey]; !ok {
+				util.ExitInputErr("Asset '%s' is removing label reference '%s' which does not exist in 'labels'.", ta.Str(), labelKey)
+			}
+		}
 		if ta.Zone != nil {
 			if zone := ts.ZoneById(*ta.Zone); zone == nil {
-				util.ExitErr("Asset '%s' is using zone ID %d which does not exist in 'zones'.", ta.Str(), *ta.Zone)
+				util.ExitInputErr("Asset '%s' is using zone ID %d which does not exist in 'zones'.", ta.Str(), *ta.Zone)
 			}
 		}
 
 		if ta.Id == 0 {
 			// New asset
 			if ta.Name == "" {
-				util.ExitErr("One or more assets are missing both an 'id' and a 'name'. At least one of these attributes is required for each asset.")
+				util.ExitInputErr("One or more assets are missing both an 'id' and a 'name'. At least one of these attributes is required for each asset.")
 			}
 			changes = append(changes, &Change{
 				info: fmt.Sprintf("Create new asset: %s", cval(ta.Name)),
 				task: TaskCreateAsset{asset: ta},
 			})
-			assetChanges(&changes, purge, &cli.DefaultAsset, ta, cs, ts)
+			assetChanges(&changes, cmd.Purge, &cli.DefaultAsset, ta, cs, ts)
 		} else {
 			ca := cs.AssetById(ta.Id)
 			if ca == nil {
-				util.ExitErr("Asset ID %d not found in container '%s'.", ta.Id, cs.Container.Str())
+				util.ExitErrCode(util.ExitCodeNotFound, "Asset ID %d not found in container '%s'.", ta.Id, cs.Container.Str())
+			}
+			assetChanges(&changes, cmd.Purge, ca, ta, cs, ts)
+		}
+	}
+
+	//
+	// Container purge; must be last as these changes are destructive
+	//
+	for _, ta := range ts.Assets {
+		if ta.IsAbsent() && ta.Id != 0 {
+			if ca := cs.AssetById(ta.Id); ca != nil {
+				changes = append(changes, &Change{
+					info:        fmt.Sprintf("Delete asset '%s' (ID %s)", cval(ca.Str()), cval(ca.Id)),
+					task:        TaskDeleteAsset{asset: ca},
+					destructive: true,
+				})
+			}
+		}
+	}
+	if cmd.PurgeAssets {
+		for _, ca := range cs.Assets {
+			if ts.AssetById(ca.Id) == nil {
+				changes = append(changes, &Change{
+					info:        fmt.Sprintf("Delete asset '%s' (ID %s)", cval(ca.Str()), cval(ca.Id)),
+					task:        TaskDeleteAsset{asset: ca},
+					destructive: true,
+				})
+			}
+		}
+	}
+	if cmd.PurgeLabels {
+		// The API has no documented request to list the labels of a
+		// container, so only labels which are used by current assets are
+		// found
+		labels := []*cli.Label{}
+		for _, cl := range cs.Labels {
+			if ts.LabelById(cl.Id) == nil {
+				labels = append(labels, cl)
+			}
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Id < labels[j].Id })
+		for _, cl := range labels {
+			if ca := labelInUse(cmd, cs, ts, cl.Id); ca != nil {
+				fmt.Fprintf(os.Stderr, "Warning: label '%s' is not deleted as asset '%s' is still using this label.\n", cl.Str(), ca.Str())
+				continue
 			}
-			assetChanges(&changes, purge, ca, ta, cs, ts)
+			changes = append(changes, &Change{
+				info:        fmt.Sprintf("Delete label '%s' (ID %s)", cval(cl.Str()), cval(cl.Id)),
+				task:        TaskDeleteLabel{label: cl},
+				destructive: true,
+			})
 		}
 	}
 	return changes
 }
 
-func getCacheState(containerId int) *cli.State {
-	state := cli.StateFromCache(containerId)
+// labelInUse returns a current asset which will still have the given label
+// after applying, or nil if no such asset exists.
+func labelInUse(cmd *TApply, cs, ts *cli.State, labelId int) *cli.AssetCli {
+	for _, ca := range cs.Assets {
+		if !ca.HasLabelId(labelId, cs.GetLabelMap()) {
+			continue
+		}
+		if ta := ts.AssetById(ca.Id); ta == nil {
+			if !cmd.PurgeAssets {
+				return ca
+			}
+		} else if ta.IsAbsent() || ta.HasAbsentLabelId(labelId, ts.GetLabelMap()) {
+			continue
+		} else if ta.Labels == nil || !cmd.Purge {
+			return ca
+		}
+	}
+	return nil
+}
+
+func countDestructive(changes []*Change) int {
+	n := 0
+	for _, c := range changes {
+		if c.destructive {
+			n += 1
+		}
+	}
+	return n
+}
+
+func printChanges(changes []*Change) {
+	fmt.Println("")
+	for _, c := range changes {
+		if !c.destructive {
+			fmt.Printf("- %s\n", c.info)
+		}
+	}
+	if countDestructive(changes) > 0 {
+		fmt.Println("")
+		fmt.Println(color.HiRedString("Destructive changes:"))
+		fmt.Println("")
+		for _, c := range changes {
+			if c.destructive {
+				fmt.Printf("- %s\n", c.info)
+			}
+		}
+	}
+	fmt.Println("")
+}
+
+func getCacheState(cmd *TApply, containerId int) *cli.State {
+	if cmd.PlanOut != "" {
+		return nil // A plan must be created for the actual remote state
+	}
+	// Unlike other questions, --yes does not answer this one; without
+	// --use-cache a fresh state is read
+	useCache := cmd.UseCache
+	if (useCache == nil && cmd.Yes) || (useCache != nil && !*useCache) || cmd.CacheTtl <= 0 {
+		return nil
+	}
+	state := cli.StateFromCache(cmd.Api, containerId)
 	if state != nil {
 		if age, err := state.GetAge(); err == nil {
-			if age.Hours() < 8 {
-				util.Color("A cache for container ID %d was found that is only %s old. Would you like to use it? (yes/no): ", containerId, util.HumanizeDuration(*age))
-				if util.AskForConfirmation() {
+			if *age < cmd.CacheTtl {
+				if util.Ask(useCache, "A cache for container ID %d was found that is only %s old. Would you like to use it? (yes/no): ", containerId, util.HumanizeDuration(*age)) {
 					return state
 				}
 			}
@@ -512,7 +800,7 @@ func getCacheState(containerId int) *cli.State {
 func sanitizeConfig(input map[string]any) map[string]any {
 	clone := make(map[string]any)
 	for k, v := range input {
-		if k == "password" || k == "secret" {
+		if cli.IsSecretKey(k) {
 			clone[k] = "xxx"
 			continue
 		}
@@ -523,17 +811,20 @@ func sanitizeConfig(input map[string]any) map[string]any {
 
 func sanityCheckCollectorConfig(ts *cli.State, cMap map[string]*cli.Collector, api, token string, remoteValidation bool) {
 	for _, asset := range ts.Assets {
-		if asset.Collectors == nil {
+		if asset.Collectors == nil || asset.IsAbsent() {
 			continue
 		}
 		for _, collector := range *asset.Collectors {
+			if collector.IsAbsent() {
+				continue
+			}
 			if c, ok := cMap[collector.Key]; ok {
 				for k, v := range collector.Config {
 					found := false
 					for _, o := range c.Options {
 						if o.Key == k {
 							found = true
-							if o.Type == "String" && (k == "password" || k == "secret") {
+							if o.Type == "String" && cli.IsSecretKey(k) {
 								if _, ok := v.(string); ok {
 									break
 								}
@@ -544,24 +835,24 @@ func sanityCheckCollectorConfig(ts *cli.State, cMap map[string]*cli.Collector, a
 										}
 									}
 								}
-								util.ExitErr("Collector '%s' on asset '%s' expects property '%s' to be a string or encryption value", collector.Key, asset.Str(), k)
+								util.ExitInputErr("Collector '%s' on asset '%s' expects property '%s' to be a string or encryption value", collector.Key, asset.Str(), k)
 							}
 							switch o.Type {
 							case "Bool":
 								if _, ok := v.(bool); !ok {
-									util.ExitErr("Collector '%s' on asset '%s' expects a boolean value for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
+									util.ExitInputErr("Collector '%s' on asset '%s' expects a boolean value for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
 								}
 							case "Int":
 								if _, ok := v.(int); !ok {
-									util.ExitErr("Collector '%s' on asset '%s' expects an integer value for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
+									util.ExitInputErr("Collector '%s' on asset '%s' expects an integer value for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
 								}
 							case "Float":
 								if _, ok := v.(float64); !ok {
-									util.ExitErr("Collector '%s' on asset '%s' expects a floating point for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
+									util.ExitInputErr("Collector '%s' on asset '%s' expects a floating point for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
 								}
 							case "String":
 								if _, ok := v.(string); !ok {
-									util.ExitErr("Collector '%s' on asset '%s' expects a string value for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
+									util.ExitInputErr("Collector '%s' on asset '%s' expects a string value for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
 								}
 							case "ListBool", "ListInt", "ListFloat", "ListString":
 								if arr, ok := v.([]any); ok {
@@ -569,44 +860,44 @@ func sanityCheckCollectorConfig(ts *cli.State, cMap map[string]*cli.Collector, a
 									case "ListBool":
 										for _, v := range arr {
 											if _, ok = v.(bool); !ok {
-												util.ExitErr("Collector '%s' on asset '%s' expects a list of boolean values for property '%s' but the list contains type %T", collector.Key, asset.Str(), k, v)
+												util.ExitInputErr("Collector '%s' on asset '%s' expects a list of boolean values for property '%s' but the list contains type %T", collector.Key, asset.Str(), k, v)
 											}
 										}
 									case "ListInt":
 										for _, v := range arr {
 											if _, ok = v.(int); !ok {
-												util.ExitErr("Collector '%s' on asset '%s' expects a list of integer values for property '%s' but the list contains type %T", collector.Key, asset.Str(), k, v)
+												util.ExitInputErr("Collector '%s' on asset '%s' expects a list of integer values for property '%s' but the list contains type %T", collector.Key, asset.Str(), k, v)
 											}
 										}
 									case "ListFloat":
 										for _, v := range arr {
 											if _, ok = v.(float64); !ok {
-												util.ExitErr("Collector '%s' on asset '%s' expects a list of floating point values for property '%s' but the list contains type %T", collector.Key, asset.Str(), k, v)
+												util.ExitInputErr("Collector '%s' on asset '%s' expects a list of floating point values for property '%s' but the list contains type %T", collector.Key, asset.Str(), k, v)
 											}
 										}
 									case "ListString":
 										for _, v := range arr {
 											if _, ok = v.(string); !ok {
-												util.ExitErr("Collector '%s' on asset '%s' expects a list of string values for property '%s' but the list contains type %T", collector.Key, asset.Str(), k, v)
+												util.ExitInputErr("Collector '%s' on asset '%s' expects a list of string values for property '%s' but the list contains type %T", collector.Key, asset.Str(), k, v)
 											}
 										}
 									}
 									break
 								}
-								util.ExitErr("Collector '%s' on asset '%s' expects a list of values for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
+								util.ExitInputErr("Collector '%s' on asset '%s' expects a list of values for property '%s' but found type %T", collector.Key, asset.Str(), k, v)
 							}
 							break
 						}
 					}
 					if !found {
-						util.ExitErr("Collector '%s' on asset '%s' contains an unknown configuration property '%s'.", collector.Key, asset.Str(), k)
+						util.ExitInputErr("Collector '%s' on asset '%s' contains an unknown configuration property '%s'.", collector.Key, asset.Str(), k)
 					}
 				}
 			}
 			if remoteValidation {
 				err := req.VerifyCollectorConfig(api, token, collector.Key, sanitizeConfig(collector.Config))
 				if err != nil {
-					util.ExitErr("Collector '%s' on asset '%s': %s", collector.Key, asset.Str(), err)
+					util.ExitErrFor(err, "Collector '%s' on asset '%s': %s", collector.Key, asset.Str(), infrasonar.ErrorMessage(err))
 				}
 			}
 		}
@@ -722,7 +1013,7 @@ func niceAssetKinds(api string, assets []*cli.AssetCli) {
 		if asset.Kind != "" {
 			kind := util.InSlice(kinds, asset.Kind)
 			if kind == nil {
-				util.ExitErr("Asset '%s' has an invalid asset kind: %s", asset.Str(), asset.Kind)
+				util.ExitInputErr("Asset '%s' has an invalid asset kind: %s", asset.Str(), asset.Kind)
 			}
 			asset.Kind = *kind
 		}
@@ -741,49 +1032,71 @@ func updateCollectorMap(api, token string, containerId int, cMap *map[string]*cl
 	return nil
 }
 
-func Apply(api, token, filename string, dryRun, purge bool) {
-	if dryRun {
+func Apply(cmd *TApply) {
+	stdout := os.Stdout
+	if cmd.PlanFormat != "" {
+		// Keep stdout clean for the plan; progress is written to stderr
+		os.Stdout = os.Stderr
+	}
+	if cmd.DryRun {
 		util.Color(`-----------------------------------------
   Simulation :: no changes will be made
 -----------------------------------------
 `)
 	}
+	if cmd.WriteBack && (cmd.Resume || cmd.PlanIn != "") {
+		util.ExitInputErr("--write-back cannot be used with --resume or --plan-in")
+	}
+	if cmd.Resume {
+		resume(cmd)
+	}
+	if cmd.PlanIn != "" {
+		applyPlanFile(cmd)
+	}
 	fmt.Println("Read input file...")
-	ts, err := cli.StateFromFile(filename)
-	util.ExitOnErr(err)
+	ts, err := cli.StateFromFile(cmd.Filename)
+	util.ExitOnErr(util.InputErr(err))
+
+	if ts.Container == nil || ts.Container.Id == 0 {
+		util.ExitInputErr("missing container ID in input file")
+	}
+	for _, ta := range ts.Assets {
+		checkPresence(ta)
+	}
 
-	if ts.Container.Id == 0 {
-		util.ExitErr("missing container ID in input file")
+	if cmd.WriteBack {
+		// Must be created before IDs are set by matching or creating assets
+		cmd.wb, err = newWriteBack(cmd.Filename, ts)
+		util.ExitOnErr(util.InputErr(err))
 	}
 
-	if !dryRun {
+	if !cmd.DryRun {
+		lockContainer(cmd.Api, ts.Container.Id)
+
 		fmt.Println("Check token permissions...")
-		me, err := req.GetMe(api, token, ts.Container.Id)
+		me, err := req.GetMe(cmd.Api, cmd.Token, ts.Container.Id)
 		util.ExitOnErr(err)
-		util.ExitOnErr(me.CheckApplyPermissions())
+		util.ExitOnErr(cli.CheckApplyPermissions(me))
 	}
 
-	cs := getCacheState(ts.Container.Id)
+	cs := getCacheState(cmd, ts.Container.Id)
 	if cs == nil {
-		fmt.Println("Read current state...")
-		cs = ensureState(&TGetAssets{
-			Api:             api,
-			Token:           token,
-			Output:          "",
-			OutFn:           "-", // Force progress output, nothing will be written
-			Container:       ts.Container.Id,
-			Asset:           0,
-			Properties:      cli.AssetProperties,
-			Filters:         []string{},
-			IncludeDefaults: true,
-		})
-		cs.WriteCache()
+		cs = readState(cmd, ts.Container.Id)
+		if cmd.CacheTtl > 0 {
+			if err := cs.WriteCache(cmd.Api); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+			}
+		}
 	}
 
+	// Must be taken before the current state is modified below
+	fingerprint, err := cs.Fingerprint()
+	util.ExitOnErr(err)
+
 	cMap := map[string]*cli.Collector{}
 
 	if ts.HasAssetKind() {
-		niceAssetKinds(api, ts.Assets)
+		niceAssetKinds(cmd.Api, ts.Assets)
 	}
 
 	if ts.HasCollector() {
@@ -792,70 +1105,124 @@ func Apply(api, token, filename string, dryRun, purge bool) {
 		revertUse(ts.Assets)
 
 		fmt.Println("Read collectors...")
-		util.ExitOnErr(updateCollectorMap(api, token, ts.Container.Id, &cMap))
+		util.ExitOnErr(updateCollectorMap(cmd.Api, cmd.Token, ts.Container.Id, &cMap))
 
 		changes := ensureCollectors(ts, cMap)
 		n := len(changes)
-		if n > 0 {
-			if dryRun {
-				util.Color("To run a more accurate dry run, %d collector%s need to be enabled. Proceed? (yes/no): ", n, util.Plural(n))
-			} else {
-				util.Color("To continue, %d collector%s must be enabled. Proceed? (yes/no): ", n, util.Plural(n))
+		if n > 0 && cmd.PlanOut != "" {
+			// Writing a plan must not change the remote state; the plan
+			// enables the collectors, see ensureChanges and
+			// enablePlanCollectors
+			fmt.Printf("The plan enables %d collector%s; collector configurations are checked when the plan is applied.\n", n, util.Plural(n))
+		} else if n > 0 {
+			question := "To continue, %d collector%s must be enabled. Proceed? (yes/no): "
+			if cmd.DryRun {
+				question = "To run a more accurate dry run, %d collector%s need to be enabled. Proceed? (yes/no): "
 			}
-			if util.AskForConfirmation() {
-				ts.ClearCache() // Clear the cache as we're about to make changes
+			if util.Ask(cmd.answer(nil), question, n, util.Plural(n)) {
+				ts.ClearCache(cmd.Api) // Clear the cache as we're about to make changes
 				fmt.Println("")
-				processChanges(api, token, ts.Container.Id, &changes)
+				processChanges(cmd, ts.Container.Id, changes, nil)
 				fmt.Println("")
 			} else {
-				util.ExitOk("Cancelled.")
+				util.ExitCancelled()
 			}
 			// We need this sleep, for the collector changes
 			time.Sleep(1 * time.Second)
 
 			fmt.Println("Read collectors...")
-			util.ExitOnErr(updateCollectorMap(api, token, ts.Container.Id, &cMap))
+			util.ExitOnErr(updateCollectorMap(cmd.Api, cmd.Token, ts.Container.Id, &cMap))
 		}
 
 		ensureNumbersAndDefaults(cs.Assets, cMap)
 		ensureNumbersAndDefaults(ts.Assets, cMap)
 
-		util.Color("Perform remote configuration check? (Local check is faster, remote is more thorough) (yes/no): ")
-		remoteValidation := util.AskForConfirmation()
+		remoteValidation := util.Ask(cmd.answer(cmd.RemoteValidate), "Perform remote configuration check? (Local check is faster, remote is more thorough) (yes/no): ")
 		if remoteValidation {
 			fmt.Println("Checking configs remotely... (this may take a moment)")
 		}
 		fmt.Println("")
-		sanityCheckCollectorConfig(ts, cMap, api, token, remoteValidation)
+		sanityCheckCollectorConfig(ts, cMap, cmd.Api, cmd.Token, remoteValidation)
 	}
 
-	changes := ensureChanges(api, token, purge, cs, ts, cMap)
+	if cmd.MatchBy != "" {
+		matchAssets(cs, ts, cmd.MatchBy)
+	}
+
+	changes := ensureChanges(cmd, cs, ts, cMap)
 	n := len(changes)
 
+	if cmd.PlanFormat != "" {
+		os.Stdout = stdout
+		util.ExitOutput(getPlan(cs.Container, changes), cmd.PlanFormat, "")
+	}
+
+	if cmd.PlanOut != "" {
+		util.ExitOnErr(WritePlanFile(cmd.PlanOut, cmd.Api, ts.Container.Id, fingerprint, changes))
+		util.ExitOk("Plan with %d change%s written to: %s", n, util.Plural(n), cmd.PlanOut)
+	}
+
+	applyChanges(cmd, ts.Container.Id, changes, nil)
+}
+
+func readState(cmd *TApply, containerId int) *cli.State {
+	fmt.Println("Read current state...")
+	return ensureState(&TGetAssets{
+		Api:             cmd.Api,
+		Token:           cmd.Token,
+		Output:          "",
+		OutFn:           "-", // Force progress output, nothing will be written
+		Container:       strconv.Itoa(containerId),
+		Asset:           0,
+		Properties:      cli.AssetProperties,
+		Filters:         []string{},
+		IncludeDefaults: true,
+	})
+}
+
+func applyChanges(cmd *TApply, containerId int, changes []*Change, j *journal) {
+	n := len(changes)
 	if n == 0 {
+		cmd.writeIds() // IDs of matched assets
 		util.ExitOk("No changes found.")
 	}
 
-	util.Color("Found %d change%s. Show details? (yes/no): ", n, util.Plural(n))
-	if util.AskForConfirmation() {
-		fmt.Println("")
-		for _, c := range changes {
-			fmt.Printf("- %s\n", c.info)
-		}
-		fmt.Println("")
+	if nd := countDestructive(changes); nd > 0 {
+		util.Color("Found %d change%s, of which %d %s destructive.\n", n, util.Plural(n), nd, util.IsAre(nd))
+	} else {
+		util.Color("Found %d change%s.\n", n, util.Plural(n))
+	}
+	if util.Ask(cmd.answer(cmd.ShowDetails), "Show details? (yes/no): ") {
+		printChanges(changes)
 	}
 
-	if dryRun {
+	if cmd.DryRun {
 		util.ExitOk("Done. (no changes made)")
 	} else {
-		util.Color("Do you want to apply the change%s? (yes/no): ", util.Plural(n))
-		if util.AskForConfirmation() {
-			ts.ClearCache() // Clear the cache as we're about to make changes
+		if j == nil && hasJournal(cmd.Api, containerId) {
+			util.Color("Warning: an unfinished apply for this container will be discarded. Use --resume to continue it instead.\n")
+		}
+		if ni := countIrreversible(changes); cmd.Atomic && ni > 0 {
+			util.Color("Warning: %d change%s cannot be rolled back when a later change fails.\n", ni, util.Plural(ni))
+		}
+		if util.Ask(cmd.answer(nil), "Do you want to apply the change%s? (yes/no): ", util.Plural(n)) {
+			if nd := countDestructive(changes); nd > 0 {
+				if !util.Ask(cmd.confirmDestructive(nd), "%d destructive change%s cannot be undone. Are you sure? (yes/no): ", nd, util.Plural(nd)) {
+					util.ExitCancelled()
+				}
+			}
+			cli.ClearCache(cmd.Api, containerId) // Clear the cache as we're about to make changes
+			if j == nil {
+				var err error
+				j, err = newJournal(cmd.Api, containerId, changes)
+				util.ExitOnErr(err)
+			}
 			fmt.Println("")
-			processChanges(api, token, ts.Container.Id, &changes)
+			processChanges(cmd, containerId, changes, j)
 			fmt.Println("")
+			cmd.writeIds()
 			util.ExitOk("Done.")
 		}
-		util.ExitOk("Cancelled.")
+		util.ExitCancelled()
 	}
 }
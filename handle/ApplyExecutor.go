@@ -0,0 +1,327 @@
+package handle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+// Tasks are executed in phases. A phase only starts when the previous phase
+// has finished. Within a phase, tasks are grouped in chains; the tasks of a
+// chain run in order, while chains may run concurrently.
+const (
+	phaseGlobal       = iota // Zones, labels and collectors
+	phaseAssets              // Asset creation and changes, one chain per asset
+	phaseDeleteAssets        // Purge of assets
+	phaseDeleteOther         // Purge of labels, after they are removed from assets
+	numPhases
+)
+
+type chain []int // Indexes in the list of changes
+
+// chainKey returns the phase and the object for which tasks must run in order.
+func chainKey(c *Change) (int, any) {
+	switch t := c.task.(type) {
+	case TaskUpsertZone:
+		return phaseGlobal, t.zone
+	case TaskSetCollectorDisplay:
+		return phaseGlobal, t.collectorKey
+	case TaskCreateLabel:
+		return phaseGlobal, t.label
+	case TaskSetLabelName:
+		return phaseGlobal, t.label
+	case TaskSetLabelColor:
+		return phaseGlobal, t.label
+	case TaskSetLabelDescription:
+		return phaseGlobal, t.label
+	case TaskCreateAsset:
+		return phaseAssets, t.asset
+	case TaskSetAssetName:
+		return phaseAssets, t.asset
+	case TaskSetAssetMode:
+		return phaseAssets, t.asset
+	case TaskSetAssetKind:
+		return phaseAssets, t.asset
+	case TaskSetAssetZone:
+		return phaseAssets, t.asset
+	case TaskSetAssetDescription:
+		return phaseAssets, t.asset
+	case TaskAddLabelToAsset:
+		return phaseAssets, t.asset
+	case TaskDeleteLabelFromAsset:
+		return phaseAssets, t.asset
+	case TaskEnableAssetCheck:
+		return phaseAssets, t.asset
+	case TaskDisableAssetCheck:
+		return phaseAssets, t.asset
+	case TaskUpsertCollectorToAsset:
+		return phaseAssets, t.asset
+	case TaskRemoveCollectorFromAsset:
+		return phaseAssets, t.asset
+	case TaskDeleteAsset:
+		return phaseDeleteAssets, t.asset
+	case TaskDeleteLabel:
+		return phaseDeleteOther, t.label
+	}
+	return phaseAssets, c // Unknown tasks get their own chain
+}
+
+// getPhases groups the changes in phases and chains. The order of tasks
+// within a chain equals the order of the changes.
+func getPhases(changes []*Change) [numPhases][]chain {
+	var phases [numPhases][]chain
+	var index [numPhases]map[any]int
+	for i, c := range changes {
+		phase, key := chainKey(c)
+		if index[phase] == nil {
+			index[phase] = map[any]int{}
+		}
+		idx, ok := index[phase][key]
+		if !ok {
+			idx = len(phases[phase])
+			index[phase][key] = idx
+			phases[phase] = append(phases[phase], chain{})
+		}
+		phases[phase][idx] = append(phases[phase][idx], i)
+	}
+	return phases
+}
+
+type executor struct {
+	api         string
+	token       string
+	containerId int
+	changes     []*Change
+	journal     *journal
+	out         io.Writer
+
+	mu    sync.Mutex
+	total int       // Number of tasks not done by a previous run
+	done  []*Change // Processed changes, in order of completion
+	errs  []error
+
+	// Progress is printed in the order of the changes, not in order of
+	// completion; lines are buffered until all tasks before are finished
+	order []int          // Indexes of the tasks of the current phase
+	next  int            // Position in order of the next line to print
+	lines map[int]string // Buffered lines by index
+}
+
+func (e *executor) failed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.errs) > 0
+}
+
+// runChain processes the tasks of a chain in order and stops at the first
+// error or when another chain has failed.
+func (e *executor) runChain(ch chain) {
+	n := len(e.changes)
+	for _, i := range ch {
+		c := e.changes[i]
+		if e.journal != nil && e.journal.isDone(i) {
+			continue
+		}
+		if e.failed() {
+			return
+		}
+		if e.journal != nil {
+			if err := e.journal.started(i); err != nil {
+				e.fail(err)
+				return
+			}
+		}
+
+		err := processTask(e.api, e.token, e.containerId, c)
+
+		e.mu.Lock()
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("task %d/%d (%s): %w", i+1, n, c.info, err))
+			e.report(i, fmt.Sprintf("Processing task %d/%d: %s ... failed\n", i+1, n, c.info))
+			e.mu.Unlock()
+			return
+		}
+		e.done = append(e.done, c)
+		e.report(i, fmt.Sprintf("Processing task %d/%d: %s ...\n", i+1, n, c.info))
+		e.mu.Unlock()
+
+		if e.journal != nil {
+			if err := e.journal.done(i, c); err != nil {
+				e.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// report buffers the progress line of a task and prints all lines which are
+// next in order. Must be called with the lock held.
+func (e *executor) report(i int, line string) {
+	e.lines[i] = line
+	for e.next < len(e.order) {
+		line, ok := e.lines[e.order[e.next]]
+		if !ok {
+			break
+		}
+		fmt.Fprint(e.out, line)
+		e.next++
+	}
+}
+
+// flush prints the remaining lines of a phase; after a failure, tasks which
+// have not run have no line.
+func (e *executor) flush() {
+	for _, i := range e.order[e.next:] {
+		if line, ok := e.lines[i]; ok {
+			fmt.Fprint(e.out, line)
+		}
+	}
+}
+
+func (e *executor) fail(err error) {
+	e.mu.Lock()
+	e.errs = append(e.errs, err)
+	e.mu.Unlock()
+}
+
+// runPhase runs the chains of a phase using at most the given number of
+// workers.
+func (e *executor) runPhase(chains []chain, parallel int) {
+	e.order, e.next, e.lines = []int{}, 0, map[int]string{}
+	for _, ch := range chains {
+		for _, i := range ch {
+			if e.journal == nil || !e.journal.isDone(i) {
+				e.order = append(e.order, i)
+			}
+		}
+	}
+	slices.Sort(e.order)
+
+	queue := make(chan chain)
+	var wg sync.WaitGroup
+	for w := 0; w < min(parallel, len(chains)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ch := range queue {
+				e.runChain(ch)
+			}
+		}()
+	}
+	for _, ch := range chains {
+		if e.failed() {
+			break
+		}
+		queue <- ch
+	}
+	close(queue)
+	wg.Wait()
+	e.flush()
+}
+
+// processChanges runs all changes. When a journal is given, tasks which are
+// already done are skipped and progress is written to the journal. With more
+// than one worker, independent tasks run concurrently.
+func processChanges(cmd *TApply, containerId int, changes []*Change, j *journal) {
+	n := len(changes)
+	if j != nil {
+		for i, c := range changes {
+			if j.unconfirmed(i) && createdBy(c) != nil {
+				if !util.Ask(cmd.answer(nil), "Task %d/%d (%s) was started but never confirmed; it might have been applied. Run it again? (yes/no): ", i+1, n, c.info) {
+					util.ExitCancelled()
+				}
+			}
+		}
+	}
+
+	if cmd.Parallel > 1 {
+		processParallel(cmd, containerId, changes, j)
+		return
+	}
+
+	done := []*Change{} // Changes processed by this run
+	for i, c := range changes {
+		if j != nil {
+			if j.isDone(i) {
+				fmt.Printf("Skipping task %d/%d: %s (already done)\n", i+1, n, c.info)
+				continue
+			}
+			util.ExitOnErr(j.started(i))
+		}
+		fmt.Printf("Processing task %d/%d: %s ...\n", i+1, n, c.info)
+		if err := processTask(cmd.Api, cmd.Token, containerId, c); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			processFailed(cmd, containerId, done, j, err)
+		}
+		done = append(done, c)
+		if j != nil {
+			util.ExitOnErr(j.done(i, c))
+		}
+	}
+	if j != nil {
+		j.remove()
+	}
+}
+
+func processParallel(cmd *TApply, containerId int, changes []*Change, j *journal) {
+	e := &executor{
+		api:         cmd.Api,
+		token:       cmd.Token,
+		containerId: containerId,
+		changes:     changes,
+		journal:     j,
+		out:         os.Stdout,
+	}
+	for i := range changes {
+		if j == nil || !j.isDone(i) {
+			e.total += 1
+		}
+	}
+	if skipped := len(changes) - e.total; skipped > 0 {
+		fmt.Printf("Skipping %d task%s (already done)\n", skipped, util.Plural(skipped))
+	}
+
+	for _, chains := range getPhases(changes) {
+		e.runPhase(chains, cmd.Parallel)
+		if len(e.errs) > 0 {
+			break
+		}
+	}
+
+	if len(e.errs) > 0 {
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, color.HiRedString("%d task%s failed:", len(e.errs), util.Plural(len(e.errs))))
+		err := errors.Join(e.errs...)
+		fmt.Fprintln(os.Stderr, err)
+		processFailed(cmd, containerId, e.done, j, err)
+	}
+	if j != nil {
+		j.remove()
+	}
+}
+
+// processFailed handles a failed task and exits with an exit code for the
+// error. The given changes are the changes which are processed by this run
+// before the failure; tasks done by a previous run are never rolled back.
+func processFailed(cmd *TApply, containerId int, done []*Change, j *journal, err error) {
+	if cmd.Atomic {
+		rollback(cmd.Api, cmd.Token, containerId, done)
+		if j != nil {
+			j.remove()
+		}
+	} else {
+		cmd.writeIds() // Created assets and labels are not rolled back
+		if j != nil {
+			j.close()
+			fmt.Fprintln(os.Stderr, "")
+			fmt.Fprintln(os.Stderr, "Progress is saved. Run the same command with --resume to continue.")
+		}
+	}
+	os.Exit(util.ExitCode(err))
+}
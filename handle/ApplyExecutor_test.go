@@ -0,0 +1,107 @@
+package handle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+func TestGetPhases(t *testing.T) {
+	asset1 := &cli.AssetCli{Id: 11}
+	asset2 := &cli.AssetCli{Name: "new"}
+	label := &cli.Label{Name: "new"}
+	old := &cli.AssetCli{Id: 12}
+	oldLabel := &cli.Label{Id: 100}
+
+	changes := []*Change{
+		{task: TaskSetAssetName{asset: asset1}},                       // 0
+		{task: TaskCreateLabel{label: label}},                         // 1
+		{task: TaskCreateAsset{asset: asset2}},                        // 2
+		{task: TaskDeleteLabel{label: oldLabel}},                      // 3
+		{task: TaskAddLabelToAsset{asset: asset2, label: label}},      // 4
+		{task: TaskDeleteAsset{asset: old}},                           // 5
+		{task: TaskSetAssetMode{asset: asset1}},                       // 6
+		{task: TaskSetLabelColor{label: label}},                       // 7
+		{task: TaskSetCollectorDisplay{collectorKey: "tcp"}},          // 8
+		{task: TaskDeleteLabelFromAsset{asset: old, label: oldLabel}}, // 9
+	}
+
+	want := [numPhases][]chain{
+		phaseGlobal:       {{1, 7}, {8}},
+		phaseAssets:       {{0, 6}, {2, 4}, {9}},
+		phaseDeleteAssets: {{5}},
+		phaseDeleteOther:  {{3}},
+	}
+	if got := getPhases(changes); !reflect.DeepEqual(got, want) {
+		t.Errorf("getPhases = %v, want %v", got, want)
+	}
+}
+
+func TestRunPhaseOrderedOutput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/asset/11/name":
+			time.Sleep(100 * time.Millisecond)
+		case "/asset/12/name":
+			time.Sleep(50 * time.Millisecond)
+		case "/asset/14/name":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	}))
+	defer srv.Close()
+
+	changes := []*Change{}
+	for _, id := range []int{11, 12, 13, 14} {
+		a := &cli.AssetCli{Id: id, Name: "new"}
+		changes = append(changes, &Change{info: strconv.Itoa(id), task: TaskSetAssetName{asset: a}})
+	}
+	var out strings.Builder
+	e := &executor{api: srv.URL, containerId: 5, changes: changes, out: &out}
+	e.runPhase(getPhases(changes)[phaseAssets], 4)
+
+	want := "Processing task 1/4: 11 ...\n" +
+		"Processing task 2/4: 12 ...\n" +
+		"Processing task 3/4: 13 ...\n" +
+		"Processing task 4/4: 14 ... failed\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if len(e.done) != 3 || len(e.errs) != 1 {
+		t.Errorf("got %d done and %d failed tasks, want 3 and 1", len(e.done), len(e.errs))
+	}
+}
+
+func TestProcessParallelStopsAfterFailedPhase(t *testing.T) {
+	code, out := runExit(t, func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/container/5/label" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			}
+		}))
+		defer srv.Close()
+
+		label := &cli.Label{Name: "new"}
+		asset := &cli.AssetCli{Id: 11, Name: "web"}
+		changes := []*Change{
+			{info: "create label", task: TaskCreateLabel{label: label}},
+			{info: "set asset name", task: TaskSetAssetName{asset: asset}},
+		}
+		processParallel(&TApply{Api: srv.URL, Parallel: 2}, 5, changes, nil)
+	})
+	if code != util.ExitCodeValidation {
+		t.Errorf("exit code = %d, want %d", code, util.ExitCodeValidation)
+	}
+	if !strings.Contains(out, "Processing task 1/2: create label ... failed") {
+		t.Errorf("output %q does not report the failed task", out)
+	}
+	if strings.Contains(out, "task 2/2") {
+		t.Errorf("output %q shows a task of the next phase", out)
+	}
+}
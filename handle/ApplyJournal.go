@@ -0,0 +1,246 @@
+package handle
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+	"github.com/infrasonar/infrasonar-cli/req"
+)
+
+const (
+	journalStarted = "started"
+	journalDone    = "done"
+)
+
+// The journal is a file with one JSON object per line. The first line holds
+// all tasks, each following line the progress of a single task. A line is
+// written before and after a task runs so an interrupted apply can be resumed.
+type journalEntry struct {
+	Task   int    `json:"task"`
+	Status string `json:"status"`
+	Id     int    `json:"id,omitempty"` // Created asset or label ID
+}
+
+type journal struct {
+	mu      sync.Mutex // Tasks may finish concurrently
+	fn      string
+	fp      *os.File
+	entries map[int]*journalEntry
+}
+
+// journalFn returns the journal file for a container; container IDs are only
+// unique for a single API, so the file name includes the API host.
+func journalFn(api string, containerId int) (string, error) {
+	cliPath, err := cli.CliPath()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cliPath, fmt.Sprintf("journal_%s_%09d.jsonl", cli.FileHost(api), containerId)), nil
+}
+
+func hasJournal(api string, containerId int) bool {
+	fn, err := journalFn(api, containerId)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(fn)
+	return err == nil
+}
+
+func newJournal(api string, containerId int, changes []*Change) (*journal, error) {
+	fn, err := journalFn(api, containerId)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(newPlanFile(api, containerId, "", changes))
+	if err != nil {
+		return nil, err
+	}
+	// The journal may contain collector credentials
+	fp, err := os.OpenFile(fn, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal '%s': %s", fn, err)
+	}
+	j := &journal{
+		fn:      fn,
+		fp:      fp,
+		entries: map[int]*journalEntry{},
+	}
+	if err := j.writeLine(data); err != nil {
+		fp.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func readJournal(api string, containerId int) (*journal, []*Change, error) {
+	fn, err := journalFn(api, containerId)
+	if err != nil {
+		return nil, nil, err
+	}
+	fp, err := os.OpenFile(fn, os.O_RDWR|os.O_APPEND, 0600)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("no journal found for container ID %d; nothing to resume", containerId)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open journal '%s': %s", fn, err)
+	}
+
+	j := &journal{
+		fn:      fn,
+		fp:      fp,
+		entries: map[int]*journalEntry{},
+	}
+
+	var f TPlanFile
+	scanner := bufio.NewScanner(fp)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	if !scanner.Scan() {
+		fp.Close()
+		return nil, nil, fmt.Errorf("journal '%s' is empty", fn)
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+		fp.Close()
+		return nil, nil, fmt.Errorf("failed to read journal '%s': %s", fn, err)
+	}
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // An incomplete line, the task was not confirmed
+		}
+		j.entries[entry.Task] = &entry
+	}
+
+	if f.Api != api || f.ContainerId != containerId {
+		fp.Close()
+		return nil, nil, fmt.Errorf("journal '%s' was created for a different API or container", fn)
+	}
+
+	changes, err := f.changes()
+	if err != nil {
+		fp.Close()
+		return nil, nil, fmt.Errorf("failed to read journal '%s': %s", fn, err)
+	}
+
+	// Restore the IDs of assets and labels which are already created
+	for i, c := range changes {
+		if entry := j.entries[i]; entry != nil && entry.Status == journalDone && entry.Id != 0 {
+			switch obj := createdBy(c).(type) {
+			case *cli.AssetCli:
+				obj.Id = entry.Id
+			case *cli.Label:
+				obj.Id = entry.Id
+			}
+		}
+	}
+	return j, changes, nil
+}
+
+func (j *journal) writeLine(data []byte) error {
+	if _, err := j.fp.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal '%s': %s", j.fn, err)
+	}
+	if err := j.fp.Sync(); err != nil {
+		return fmt.Errorf("failed to write journal '%s': %s", j.fn, err)
+	}
+	return nil
+}
+
+func (j *journal) write(entry *journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[entry.Task] = entry
+	return j.writeLine(data)
+}
+
+func (j *journal) started(i int) error {
+	return j.write(&journalEntry{Task: i, Status: journalStarted})
+}
+
+func (j *journal) done(i int, c *Change) error {
+	entry := journalEntry{Task: i, Status: journalDone}
+	switch obj := createdBy(c).(type) {
+	case *cli.AssetCli:
+		entry.Id = obj.Id
+	case *cli.Label:
+		entry.Id = obj.Id
+	}
+	return j.write(&entry)
+}
+
+func (j *journal) isDone(i int) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry := j.entries[i]
+	return entry != nil && entry.Status == journalDone
+}
+
+// unconfirmed returns true if the task was started but never confirmed.
+func (j *journal) unconfirmed(i int) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry := j.entries[i]
+	return entry != nil && entry.Status == journalStarted
+}
+
+func (j *journal) close() {
+	j.fp.Close()
+}
+
+// remove is called when all tasks are processed.
+func (j *journal) remove() {
+	j.fp.Close()
+	os.Remove(j.fn)
+}
+
+func resume(cmd *TApply) {
+	var containerId int
+	if cmd.PlanIn != "" {
+		fmt.Println("Read plan file...")
+		f, _, err := ReadPlanFile(cmd.PlanIn)
+		util.ExitOnErr(util.InputErr(err))
+		containerId = f.ContainerId
+	} else {
+		fmt.Println("Read input file...")
+		ts, err := cli.StateFromFile(cmd.Filename)
+		util.ExitOnErr(util.InputErr(err))
+		if ts.Container == nil || ts.Container.Id == 0 {
+			util.ExitInputErr("missing container ID in input file")
+		}
+		containerId = ts.Container.Id
+	}
+
+	if !cmd.DryRun {
+		lockContainer(cmd.Api, containerId)
+
+		fmt.Println("Check token permissions...")
+		me, err := req.GetMe(cmd.Api, cmd.Token, containerId)
+		util.ExitOnErr(err)
+		util.ExitOnErr(cli.CheckApplyPermissions(me))
+	}
+
+	fmt.Println("Read journal...")
+	j, changes, err := readJournal(cmd.Api, containerId)
+	util.ExitOnErr(err)
+
+	done := 0
+	for i := range changes {
+		if j.isDone(i) {
+			done += 1
+		}
+	}
+	util.Color("%d of %d task%s already done.\n", done, len(changes), util.Plural(len(changes)))
+
+	applyChanges(cmd, containerId, changes, j)
+}
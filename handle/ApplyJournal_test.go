@@ -0,0 +1,175 @@
+package handle
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("TEST_RUN_EXIT") != "" {
+		os.Exit(m.Run()) // Uses the directory of the parent, see runExit
+	}
+	// Journals, locks and caches are written to the configuration directory
+	dir, err := os.MkdirTemp("", "infrasonar-cli-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("INFRASONAR_CONFIG_DIR", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// journalChanges returns changes of which the label and asset are created
+// by the first two changes.
+func journalChanges() []*Change {
+	label := &cli.Label{Name: "new"}
+	asset := &cli.AssetCli{Name: "new"}
+	return []*Change{
+		{info: "create label", task: TaskCreateLabel{label: label}},
+		{info: "create asset", task: TaskCreateAsset{asset: asset}},
+		{info: "add label", task: TaskAddLabelToAsset{asset: asset, label: label}},
+		{info: "set asset name", task: TaskSetAssetName{asset: &cli.AssetCli{Id: 11, Name: "web"}, old: "old"}},
+	}
+}
+
+// writeJournal writes a journal as an interrupted apply would: the given
+// tasks are done, and the task started is started but not confirmed.
+func writeJournal(t *testing.T, api string, changes []*Change, done []int, started int) {
+	t.Helper()
+	j, err := newJournal(api, 5, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	ids := map[int]int{0: 200, 1: 21} // IDs set by the create tasks
+	for _, i := range done {
+		if err := j.started(i); err != nil {
+			t.Fatal(err)
+		}
+		switch obj := createdBy(changes[i]).(type) {
+		case *cli.Label:
+			obj.Id = ids[i]
+		case *cli.AssetCli:
+			obj.Id = ids[i]
+		}
+		if err := j.done(i, changes[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if started >= 0 {
+		if err := j.started(started); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadJournal(t *testing.T) {
+	api := "https://journal.test"
+	writeJournal(t, api, journalChanges(), []int{0, 1}, 2)
+
+	j, changes, err := readJournal(api, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.remove()
+
+	for i, want := range []struct{ done, unconfirmed bool }{{true, false}, {true, false}, {false, true}, {false, false}} {
+		if j.isDone(i) != want.done || j.unconfirmed(i) != want.unconfirmed {
+			t.Errorf("task %d: done %v, unconfirmed %v, want %v, %v", i, j.isDone(i), j.unconfirmed(i), want.done, want.unconfirmed)
+		}
+	}
+	// The created IDs are restored and shared with the tasks which follow
+	add := changes[2].task.(TaskAddLabelToAsset)
+	if add.label != changes[0].task.(TaskCreateLabel).label || add.asset != changes[1].task.(TaskCreateAsset).asset {
+		t.Fatal("tasks do not share the created label and asset")
+	}
+	if add.label.Id != 200 || add.asset.Id != 21 {
+		t.Errorf("label ID = %d, asset ID = %d, want 200 and 21", add.label.Id, add.asset.Id)
+	}
+
+	if _, _, err := readJournal("https://other.test", 5); err == nil {
+		t.Error("expected an error for a journal of another API")
+	}
+}
+
+func TestResumeSkipsDoneTasks(t *testing.T) {
+	for _, parallel := range []int{1, 4} {
+		t.Run(fmt.Sprintf("parallel %d", parallel), func(t *testing.T) {
+			srv, requests := recordServer(nil, nil)
+			defer srv.Close()
+			writeJournal(t, srv.URL, journalChanges(), []int{0, 1}, 2)
+
+			j, changes, err := readJournal(srv.URL, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			processChanges(&TApply{Api: srv.URL, Parallel: parallel}, 5, changes, j)
+
+			got := requests()
+			slices.Sort(got) // Independent tasks may run in any order
+			want := []string{
+				`PATCH /asset/11/name {"name":"web"}`,
+				"PUT /asset/21/label/200",
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("requests = %q, want %q", got, want)
+			}
+			if hasJournal(srv.URL, 5) {
+				t.Error("journal is not removed after all tasks are done")
+			}
+		})
+	}
+}
+
+func TestResumeUnconfirmedTask(t *testing.T) {
+	t.Run("run again", func(t *testing.T) {
+		srv, requests := recordServer(nil, nil)
+		defer srv.Close()
+		writeJournal(t, srv.URL, journalChanges(), []int{0}, 1)
+
+		j, changes, err := readJournal(srv.URL, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		processChanges(&TApply{Api: srv.URL, Yes: true}, 5, changes, j)
+
+		want := []string{
+			`POST /container/5/asset {"name":"new"}`,
+			"PUT /asset/21/label/200",
+			`PATCH /asset/11/name {"name":"web"}`,
+		}
+		if got := requests(); !reflect.DeepEqual(got, want) {
+			t.Errorf("requests = %q, want %q", got, want)
+		}
+	})
+	t.Run("without an answer", func(t *testing.T) {
+		code, out := runExit(t, func() {
+			srv, _ := recordServer(os.Stdout, nil)
+			defer srv.Close()
+			writeJournal(t, srv.URL, journalChanges(), []int{0}, 1)
+
+			j, changes, err := readJournal(srv.URL, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			processChanges(&TApply{Api: srv.URL}, 5, changes, j)
+		})
+		if code != util.ExitCodeNoTerminal {
+			t.Errorf("exit code = %d, want %d", code, util.ExitCodeNoTerminal)
+		}
+		if !strings.Contains(out, "Task 2/4 (create asset) was started but never confirmed") {
+			t.Errorf("output %q does not ask to run the task again", out)
+		}
+		if strings.Contains(out, "request: ") {
+			t.Errorf("output %q shows requests before the answer", out)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package handle
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+// applyLock must be kept until the process exits; the lock is released when
+// the file is closed.
+var applyLock *cli.FileLock
+
+// lockContainer ensures only one apply at a time changes a container. As
+// container IDs are only unique per API, the lock file includes the API
+// host.
+func lockContainer(api string, containerId int) {
+	cliPath, err := cli.CliPath()
+	util.ExitOnErr(err)
+	fn := path.Join(cliPath, fmt.Sprintf("apply_%s_%09d.lock", cli.FileHost(api), containerId))
+	applyLock, err = cli.LockFile(fn, true, false)
+	if errors.Is(err, cli.ErrLocked) {
+		util.ExitErrCode(util.ExitCodeConflict, "Another apply for container ID %d is running (lock file: %s). Try again when it has finished.", containerId, fn)
+	}
+	util.ExitOnErr(err)
+}
@@ -0,0 +1,74 @@
+package handle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+const matchByName = "name"
+const matchByPropertyPrefix = "property:"
+
+// matchValue returns the value of an asset for matching, or false if the
+// asset has no such value.
+func matchValue(a *cli.AssetCli, matchBy string) (string, bool) {
+	if matchBy == matchByName {
+		return a.Name, a.Name != ""
+	}
+	key := strings.TrimPrefix(matchBy, matchByPropertyPrefix)
+	if a.Properties != nil {
+		for _, p := range *a.Properties {
+			if p.Key == key && p.Value != nil {
+				return fmt.Sprint(p.Value), true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchAssets sets the ID of target assets without an ID when exactly one
+// current asset matches. Such an asset is then updated instead of created.
+func matchAssets(cs, ts *cli.State, matchBy string) {
+	current := map[string][]*cli.AssetCli{}
+	for _, ca := range cs.Assets {
+		if v, ok := matchValue(ca, matchBy); ok {
+			current[v] = append(current[v], ca)
+		}
+	}
+
+	matched := map[int]*cli.AssetCli{}
+	for _, ta := range ts.Assets {
+		if ta.Id != 0 {
+			matched[ta.Id] = ta
+		}
+	}
+
+	for _, ta := range ts.Assets {
+		if ta.Id != 0 {
+			continue
+		}
+		v, ok := matchValue(ta, matchBy)
+		if !ok {
+			continue
+		}
+		switch matches := current[v]; len(matches) {
+		case 0:
+			continue // New asset
+		case 1:
+			ca := matches[0]
+			if other, ok := matched[ca.Id]; ok {
+				util.ExitInputErr("Asset '%s' matches asset ID %d by %s, which is already used by asset '%s' in the input file.", ta.Str(), ca.Id, matchBy, other.Str())
+			}
+			ta.Id = ca.Id
+			matched[ca.Id] = ta
+		default:
+			ids := make([]string, len(matches))
+			for i, ca := range matches {
+				ids[i] = fmt.Sprint(ca.Id)
+			}
+			util.ExitInputErr("Asset '%s' matches %d assets by %s (IDs %s); add the asset ID to the input file.", ta.Str(), len(matches), matchBy, strings.Join(ids, ", "))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package handle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+func withProperty(name, key string, value any) *cli.AssetCli {
+	return &cli.AssetCli{Name: name, Properties: &[]cli.TProperty{{Key: key, Value: value}}}
+}
+
+func TestMatchValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		asset   *cli.AssetCli
+		matchBy string
+		want    string
+		wantOk  bool
+	}{
+		{"name", &cli.AssetCli{Name: "web1"}, "name", "web1", true},
+		{"no name", &cli.AssetCli{}, "name", "", false},
+		{"string property", withProperty("web1", "serial", "A1"), "property:serial", "A1", true},
+		{"number property", withProperty("web1", "serial", 123), "property:serial", "123", true},
+		{"nil property", withProperty("web1", "serial", nil), "property:serial", "", false},
+		{"other property", withProperty("web1", "rack", "R1"), "property:serial", "", false},
+		{"no properties", &cli.AssetCli{Name: "web1"}, "property:serial", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchValue(tt.asset, tt.matchBy)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("matchValue = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestMatchAssets(t *testing.T) {
+	cs := &cli.State{Assets: []*cli.AssetCli{
+		{Id: 11, Name: "web1"},
+		{Id: 12, Name: "web2"},
+		{Id: 13, Name: "db"},
+		{Id: 14, Name: "db"},
+	}}
+
+	tests := []struct {
+		name    string
+		assets  []*cli.AssetCli
+		wantIds []int
+	}{
+		{
+			name:    "match by name",
+			assets:  []*cli.AssetCli{{Name: "web1"}, {Name: "web2"}},
+			wantIds: []int{11, 12},
+		},
+		{
+			name:    "new asset",
+			assets:  []*cli.AssetCli{{Name: "web3"}},
+			wantIds: []int{0},
+		},
+		{
+			name:    "asset with an ID",
+			assets:  []*cli.AssetCli{{Id: 13, Name: "web1"}},
+			wantIds: []int{13},
+		},
+		{
+			name:    "asset without a name",
+			assets:  []*cli.AssetCli{{Kind: "Linux"}},
+			wantIds: []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := &cli.State{Assets: tt.assets}
+			matchAssets(cs, ts, matchByName)
+			for i, ta := range ts.Assets {
+				if ta.Id != tt.wantIds[i] {
+					t.Errorf("asset %d: ID = %d, want %d", i, ta.Id, tt.wantIds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMatchAssetsByProperty(t *testing.T) {
+	cs := &cli.State{Assets: []*cli.AssetCli{
+		withProperty("web1", "serial", "A1"),
+		withProperty("web2", "serial", "A2"),
+	}}
+	cs.Assets[0].Id = 11
+	cs.Assets[1].Id = 12
+
+	ts := &cli.State{Assets: []*cli.AssetCli{withProperty("renamed", "serial", "A2")}}
+	matchAssets(cs, ts, "property:serial")
+	if ts.Assets[0].Id != 12 {
+		t.Errorf("ID = %d, want 12", ts.Assets[0].Id)
+	}
+}
+
+func TestMatchAssetsErrors(t *testing.T) {
+	cs := &cli.State{Assets: []*cli.AssetCli{
+		{Id: 11, Name: "web1"},
+		{Id: 13, Name: "db"},
+		{Id: 14, Name: "db"},
+	}}
+
+	tests := []struct {
+		name   string
+		assets []*cli.AssetCli
+		want   string
+	}{
+		{
+			name:   "ambiguous",
+			assets: []*cli.AssetCli{{Name: "db"}},
+			want:   "matches 2 assets by name (IDs 13, 14)",
+		},
+		{
+			name:   "used by an asset with an ID",
+			assets: []*cli.AssetCli{{Id: 11, Name: "other"}, {Name: "web1"}},
+			want:   "which is already used by asset",
+		},
+		{
+			name:   "used twice",
+			assets: []*cli.AssetCli{{Name: "web1"}, {Name: "web1"}},
+			want:   "which is already used by asset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out := runExit(t, func() {
+				matchAssets(cs, &cli.State{Assets: tt.assets}, matchByName)
+			})
+			if code != util.ExitCodeInput {
+				t.Errorf("exit code = %d, want %d", code, util.ExitCodeInput)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("output %q does not contain %q", out, tt.want)
+			}
+		})
+	}
+}
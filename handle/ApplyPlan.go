@@ -0,0 +1,612 @@
+package handle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+	"github.com/infrasonar/infrasonar-cli/req"
+)
+
+type TPlanChange struct {
+	Operation   string `json:"operation" yaml:"operation"`
+	TargetType  string `json:"targetType" yaml:"targetType"`
+	TargetId    int    `json:"targetId,omitempty" yaml:"targetId,omitempty"`
+	TargetName  string `json:"targetName,omitempty" yaml:"targetName,omitempty"`
+	Field       string `json:"field,omitempty" yaml:"field,omitempty"`
+	Key         string `json:"key,omitempty" yaml:"key,omitempty"`
+	OldValue    any    `json:"oldValue,omitempty" yaml:"oldValue,omitempty"`
+	NewValue    any    `json:"newValue,omitempty" yaml:"newValue,omitempty"`
+	Destructive bool   `json:"destructive,omitempty" yaml:"destructive,omitempty"`
+}
+
+type TPlan struct {
+	Container *cli.Container `json:"container" yaml:"container"`
+	Changes   []*TPlanChange `json:"changes" yaml:"changes"`
+}
+
+func assetPlan(op string, asset *cli.AssetCli, field string, old, new any) *TPlanChange {
+	return &TPlanChange{
+		Operation:  op,
+		TargetType: "asset",
+		TargetId:   asset.Id,
+		TargetName: asset.Name,
+		Field:      field,
+		OldValue:   old,
+		NewValue:   new,
+	}
+}
+
+func labelPlan(op string, label *cli.Label, field string, old, new any) *TPlanChange {
+	return &TPlanChange{
+		Operation:  op,
+		TargetType: "label",
+		TargetId:   label.Id,
+		TargetName: label.Name,
+		Field:      field,
+		OldValue:   old,
+		NewValue:   new,
+	}
+}
+
+func (c *Change) plan() *TPlanChange {
+	var p *TPlanChange
+
+	switch task := c.task.(type) {
+	case TaskUpsertZone:
+		p = &TPlanChange{
+			Operation:  "create",
+			TargetType: "zone",
+			TargetId:   task.zone.Zone,
+			TargetName: task.zone.Name,
+			Field:      "name",
+			NewValue:   task.zone.Name,
+		}
+		if task.old != nil {
+			p.Operation = "update"
+			p.OldValue = task.old.Name
+		}
+	case TaskSetCollectorDisplay:
+		p = &TPlanChange{
+			Operation:  "enable",
+			TargetType: "collector",
+			TargetName: task.collectorKey,
+		}
+		if !task.display {
+			p.Operation = "disable"
+		}
+	case TaskCreateAsset:
+		p = assetPlan("create", task.asset, "", nil, nil)
+	case TaskDeleteAsset:
+		p = assetPlan("delete", task.asset, "", nil, nil)
+	case TaskSetAssetName:
+		p = assetPlan("update", task.asset, "name", task.old, task.asset.Name)
+	case TaskSetAssetMode:
+		p = assetPlan("update", task.asset, "mode", task.old, task.asset.Mode)
+	case TaskSetAssetKind:
+		p = assetPlan("update", task.asset, "kind", task.old, task.asset.Kind)
+	case TaskSetAssetZone:
+		var old any
+		if task.old != nil {
+			old = *task.old
+		}
+		p = assetPlan("update", task.asset, "zone", old, *task.asset.Zone)
+	case TaskSetAssetDescription:
+		p = assetPlan("update", task.asset, "description", task.old, task.asset.Description)
+	case TaskAddLabelToAsset:
+		p = assetPlan("add", task.asset, "label", nil, task.label.Str())
+		p.Key = fmt.Sprintf("%d", task.label.Id)
+	case TaskDeleteLabelFromAsset:
+		p = assetPlan("remove", task.asset, "label", task.label.Str(), nil)
+		p.Key = fmt.Sprintf("%d", task.label.Id)
+	case TaskEnableAssetCheck:
+		p = assetPlan("enable", task.asset, "check", nil, nil)
+		p.Key = fmt.Sprintf("%s/%s", task.collectorKey, task.checkKey)
+	case TaskDisableAssetCheck:
+		p = assetPlan("disable", task.asset, "check", nil, nil)
+		p.Key = fmt.Sprintf("%s/%s", task.collectorKey, task.checkKey)
+	case TaskUpsertCollectorToAsset:
+		if task.old == nil {
+			p = assetPlan("add", task.asset, "collector", nil, sanitizeConfig(task.config))
+		} else {
+			p = assetPlan("update", task.asset, "collector", sanitizeConfig(task.old.Config), sanitizeConfig(task.config))
+		}
+		p.Key = task.collectorKey
+	case TaskRemoveCollectorFromAsset:
+		p = assetPlan("remove", task.asset, "collector", sanitizeConfig(task.old), nil)
+		p.Key = task.collectorKey
+	case TaskCreateLabel:
+		p = labelPlan("create", task.label, "", nil, nil)
+	case TaskDeleteLabel:
+		p = labelPlan("delete", task.label, "", nil, nil)
+	case TaskSetLabelName:
+		p = labelPlan("update", task.label, "name", task.old, task.label.Name)
+	case TaskSetLabelColor:
+		p = labelPlan("update", task.label, "color", task.old, task.label.Color)
+	case TaskSetLabelDescription:
+		p = labelPlan("update", task.label, "description", task.old, task.label.Description)
+	default:
+		panic(fmt.Sprintf("no plan for task type %T", c.task))
+	}
+
+	p.Destructive = c.destructive
+	return p
+}
+
+func getPlan(container *cli.Container, changes []*Change) *TPlan {
+	plan := TPlan{
+		Container: container,
+		Changes:   []*TPlanChange{},
+	}
+	for _, c := range changes {
+		plan.Changes = append(plan.Changes, c.plan())
+	}
+	return &plan
+}
+
+type TPlanTask struct {
+	Task         string          `json:"task"`
+	Info         string          `json:"info"`
+	Destructive  bool            `json:"destructive,omitempty"`
+	Zone         *cli.Zone       `json:"zone,omitempty"`
+	Asset        *int            `json:"asset,omitempty"`
+	Label        *int            `json:"label,omitempty"`
+	CollectorKey string          `json:"collectorKey,omitempty"`
+	CheckKey     string          `json:"checkKey,omitempty"`
+	Display      bool            `json:"display,omitempty"`
+	Config       map[string]any  `json:"config,omitempty"`
+	Old          json.RawMessage `json:"old,omitempty"`
+}
+
+// TPlanFile is a saved plan. Assets and labels are stored once and referenced
+// by index from the tasks, as tasks share them (for example an asset ID is set
+// by the create task and used by all tasks which follow).
+type TPlanFile struct {
+	Version     string          `json:"version"`
+	Api         string          `json:"api"`
+	ContainerId int             `json:"containerId"`
+	Fingerprint string          `json:"fingerprint"`
+	Assets      []*cli.AssetCli `json:"assets"`
+	Labels      []*cli.Label    `json:"labels"`
+	Tasks       []*TPlanTask    `json:"tasks"`
+
+	// For internal use only
+	assetIdx map[*cli.AssetCli]int
+	labelIdx map[*cli.Label]int
+}
+
+func (f *TPlanFile) assetRef(asset *cli.AssetCli) *int {
+	idx, ok := f.assetIdx[asset]
+	if !ok {
+		idx = len(f.Assets)
+		f.Assets = append(f.Assets, asset)
+		f.assetIdx[asset] = idx
+	}
+	return &idx
+}
+
+func (f *TPlanFile) labelRef(label *cli.Label) *int {
+	idx, ok := f.labelIdx[label]
+	if !ok {
+		idx = len(f.Labels)
+		f.Labels = append(f.Labels, label)
+		f.labelIdx[label] = idx
+	}
+	return &idx
+}
+
+func (f *TPlanFile) asset(t *TPlanTask) (*cli.AssetCli, error) {
+	if t.Asset == nil || *t.Asset < 0 || *t.Asset >= len(f.Assets) {
+		return nil, fmt.Errorf("invalid asset reference for task '%s'", t.Task)
+	}
+	return f.Assets[*t.Asset], nil
+}
+
+func (f *TPlanFile) label(t *TPlanTask) (*cli.Label, error) {
+	if t.Label == nil || *t.Label < 0 || *t.Label >= len(f.Labels) {
+		return nil, fmt.Errorf("invalid label reference for task '%s'", t.Task)
+	}
+	return f.Labels[*t.Label], nil
+}
+
+func encodeOld(old any) json.RawMessage {
+	data, err := json.Marshal(old)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (f *TPlanFile) encode(c *Change) *TPlanTask {
+	t := &TPlanTask{
+		Info:        c.info,
+		Destructive: c.destructive,
+	}
+	switch task := c.task.(type) {
+	case TaskUpsertZone:
+		t.Task = "UpsertZone"
+		t.Zone = task.zone
+		t.Old = encodeOld(task.old)
+	case TaskSetCollectorDisplay:
+		t.Task = "SetCollectorDisplay"
+		t.CollectorKey = task.collectorKey
+		t.Display = task.display
+	case TaskCreateAsset:
+		t.Task = "CreateAsset"
+		t.Asset = f.assetRef(task.asset)
+	case TaskDeleteAsset:
+		t.Task = "DeleteAsset"
+		t.Asset = f.assetRef(task.asset)
+	case TaskSetAssetName:
+		t.Task = "SetAssetName"
+		t.Asset = f.assetRef(task.asset)
+		t.Old = encodeOld(task.old)
+	case TaskSetAssetMode:
+		t.Task = "SetAssetMode"
+		t.Asset = f.assetRef(task.asset)
+		t.Old = encodeOld(task.old)
+	case TaskSetAssetKind:
+		t.Task = "SetAssetKind"
+		t.Asset = f.assetRef(task.asset)
+		t.Old = encodeOld(task.old)
+	case TaskSetAssetZone:
+		t.Task = "SetAssetZone"
+		t.Asset = f.assetRef(task.asset)
+		t.Old = encodeOld(task.old)
+	case TaskSetAssetDescription:
+		t.Task = "SetAssetDescription"
+		t.Asset = f.assetRef(task.asset)
+		t.Old = encodeOld(task.old)
+	case TaskAddLabelToAsset:
+		t.Task = "AddLabelToAsset"
+		t.Asset = f.assetRef(task.asset)
+		t.Label = f.labelRef(task.label)
+	case TaskDeleteLabelFromAsset:
+		t.Task = "DeleteLabelFromAsset"
+		t.Asset = f.assetRef(task.asset)
+		t.Label = f.labelRef(task.label)
+	case TaskEnableAssetCheck:
+		t.Task = "EnableAssetCheck"
+		t.Asset = f.assetRef(task.asset)
+		t.CollectorKey = task.collectorKey
+		t.CheckKey = task.checkKey
+	case TaskDisableAssetCheck:
+		t.Task = "DisableAssetCheck"
+		t.Asset = f.assetRef(task.asset)
+		t.CollectorKey = task.collectorKey
+		t.CheckKey = task.checkKey
+	case TaskUpsertCollectorToAsset:
+		t.Task = "UpsertCollectorToAsset"
+		t.Asset = f.assetRef(task.asset)
+		t.CollectorKey = task.collectorKey
+		t.Config = task.config
+		t.Old = encodeOld(task.old)
+	case TaskRemoveCollectorFromAsset:
+		t.Task = "RemoveCollectorFromAsset"
+		t.Asset = f.assetRef(task.asset)
+		t.CollectorKey = task.collectorKey
+		t.Old = encodeOld(task.old)
+	case TaskCreateLabel:
+		t.Task = "CreateLabel"
+		t.Label = f.labelRef(task.label)
+	case TaskDeleteLabel:
+		t.Task = "DeleteLabel"
+		t.Label = f.labelRef(task.label)
+	case TaskSetLabelName:
+		t.Task = "SetLabelName"
+		t.Label = f.labelRef(task.label)
+		t.Old = encodeOld(task.old)
+	case TaskSetLabelColor:
+		t.Task = "SetLabelColor"
+		t.Label = f.labelRef(task.label)
+		t.Old = encodeOld(task.old)
+	case TaskSetLabelDescription:
+		t.Task = "SetLabelDescription"
+		t.Label = f.labelRef(task.label)
+		t.Old = encodeOld(task.old)
+	default:
+		panic(fmt.Sprintf("cannot save task type %T", c.task))
+	}
+	return t
+}
+
+func decodeOld[T any](t *TPlanTask) (T, error) {
+	var old T
+	if len(t.Old) == 0 {
+		return old, nil
+	}
+	if err := json.Unmarshal(t.Old, &old); err != nil {
+		return old, fmt.Errorf("invalid old value for task '%s' (%s)", t.Task, err)
+	}
+	return old, nil
+}
+
+func (f *TPlanFile) decode(t *TPlanTask) (*Change, error) {
+	var err error
+	var asset *cli.AssetCli
+	var label *cli.Label
+
+	if t.Asset != nil {
+		if asset, err = f.asset(t); err != nil {
+			return nil, err
+		}
+	}
+	if t.Label != nil {
+		if label, err = f.label(t); err != nil {
+			return nil, err
+		}
+	}
+
+	// Make sure the required references are available for the task
+	needs := func(ok bool, what string) error {
+		if !ok {
+			return fmt.Errorf("missing %s for task '%s'", what, t.Task)
+		}
+		return nil
+	}
+
+	c := &Change{
+		info:        t.Info,
+		destructive: t.Destructive,
+	}
+
+	switch t.Task {
+	case "UpsertZone":
+		if err = needs(t.Zone != nil, "zone"); err == nil {
+			var old *cli.Zone
+			old, err = decodeOld[*cli.Zone](t)
+			c.task = TaskUpsertZone{zone: t.Zone, old: old}
+		}
+	case "SetCollectorDisplay":
+		c.task = TaskSetCollectorDisplay{collectorKey: t.CollectorKey, display: t.Display}
+	case "CreateAsset":
+		if err = needs(asset != nil, "asset"); err == nil {
+			c.task = TaskCreateAsset{asset: asset}
+		}
+	case "DeleteAsset":
+		if err = needs(asset != nil, "asset"); err == nil {
+			c.task = TaskDeleteAsset{asset: asset}
+		}
+	case "SetAssetName":
+		if err = needs(asset != nil, "asset"); err == nil {
+			var old string
+			old, err = decodeOld[string](t)
+			c.task = TaskSetAssetName{asset: asset, old: old}
+		}
+	case "SetAssetMode":
+		if err = needs(asset != nil, "asset"); err == nil {
+			var old string
+			old, err = decodeOld[string](t)
+			c.task = TaskSetAssetMode{asset: asset, old: old}
+		}
+	case "SetAssetKind":
+		if err = needs(asset != nil, "asset"); err == nil {
+			var old string
+			old, err = decodeOld[string](t)
+			c.task = TaskSetAssetKind{asset: asset, old: old}
+		}
+	case "SetAssetZone":
+		if err = needs(asset != nil && asset.Zone != nil, "asset zone"); err == nil {
+			var old *int
+			old, err = decodeOld[*int](t)
+			c.task = TaskSetAssetZone{asset: asset, old: old}
+		}
+	case "SetAssetDescription":
+		if err = needs(asset != nil, "asset"); err == nil {
+			var old string
+			old, err = decodeOld[string](t)
+			c.task = TaskSetAssetDescription{asset: asset, old: old}
+		}
+	case "AddLabelToAsset":
+		if err = needs(asset != nil && label != nil, "asset or label"); err == nil {
+			c.task = TaskAddLabelToAsset{asset: asset, label: label}
+		}
+	case "DeleteLabelFromAsset":
+		if err = needs(asset != nil && label != nil, "asset or label"); err == nil {
+			c.task = TaskDeleteLabelFromAsset{asset: asset, label: label}
+		}
+	case "EnableAssetCheck":
+		if err = needs(asset != nil, "asset"); err == nil {
+			c.task = TaskEnableAssetCheck{asset: asset, collectorKey: t.CollectorKey, checkKey: t.CheckKey}
+		}
+	case "DisableAssetCheck":
+		if err = needs(asset != nil, "asset"); err == nil {
+			c.task = TaskDisableAssetCheck{asset: asset, collectorKey: t.CollectorKey, checkKey: t.CheckKey}
+		}
+	case "UpsertCollectorToAsset":
+		if err = needs(asset != nil, "asset"); err == nil {
+			var old *cli.TCollector
+			old, err = decodeOld[*cli.TCollector](t)
+			c.task = TaskUpsertCollectorToAsset{asset: asset, collectorKey: t.CollectorKey, config: t.Config, old: old}
+		}
+	case "RemoveCollectorFromAsset":
+		if err = needs(asset != nil, "asset"); err == nil {
+			var old map[string]any
+			old, err = decodeOld[map[string]any](t)
+			c.task = TaskRemoveCollectorFromAsset{asset: asset, collectorKey: t.CollectorKey, old: old}
+		}
+	case "CreateLabel":
+		if err = needs(label != nil, "label"); err == nil {
+			c.task = TaskCreateLabel{label: label}
+		}
+	case "DeleteLabel":
+		if err = needs(label != nil, "label"); err == nil {
+			c.task = TaskDeleteLabel{label: label}
+		}
+	case "SetLabelName":
+		if err = needs(label != nil, "label"); err == nil {
+			var old string
+			old, err = decodeOld[string](t)
+			c.task = TaskSetLabelName{label: label, old: old}
+		}
+	case "SetLabelColor":
+		if err = needs(label != nil, "label"); err == nil {
+			var old string
+			old, err = decodeOld[string](t)
+			c.task = TaskSetLabelColor{label: label, old: old}
+		}
+	case "SetLabelDescription":
+		if err = needs(label != nil, "label"); err == nil {
+			var old string
+			old, err = decodeOld[string](t)
+			c.task = TaskSetLabelDescription{label: label, old: old}
+		}
+	default:
+		err = fmt.Errorf("unknown task '%s'", t.Task)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newPlanFile(api string, containerId int, fingerprint string, changes []*Change) *TPlanFile {
+	f := TPlanFile{
+		Version:     cli.Version,
+		Api:         api,
+		ContainerId: containerId,
+		Fingerprint: fingerprint,
+		Assets:      []*cli.AssetCli{},
+		Labels:      []*cli.Label{},
+		Tasks:       []*TPlanTask{},
+		assetIdx:    map[*cli.AssetCli]int{},
+		labelIdx:    map[*cli.Label]int{},
+	}
+	for _, c := range changes {
+		f.Tasks = append(f.Tasks, f.encode(c))
+	}
+	return &f
+}
+
+func (f *TPlanFile) changes() ([]*Change, error) {
+	changes := []*Change{}
+	for _, t := range f.Tasks {
+		c, err := f.decode(t)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+func WritePlanFile(fn, api string, containerId int, fingerprint string, changes []*Change) error {
+	f := newPlanFile(api, containerId, fingerprint, changes)
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The plan may contain collector credentials
+	if err := os.WriteFile(fn, data, 0600); err != nil {
+		return fmt.Errorf("failed to write plan '%s': %s", fn, err)
+	}
+	return nil
+}
+
+func ReadPlanFile(fn string) (*TPlanFile, []*Change, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read plan '%s': %s", fn, err)
+	}
+	var f TPlanFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal plan '%s': %s", fn, err)
+	}
+	if f.ContainerId == 0 || f.Api == "" || f.Fingerprint == "" {
+		return nil, nil, fmt.Errorf("plan '%s' is incomplete", fn)
+	}
+	changes, err := f.changes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read plan '%s': %s", fn, err)
+	}
+	return &f, changes, nil
+}
+
+func applyPlanFile(cmd *TApply) {
+	fmt.Println("Read plan file...")
+	f, changes, err := ReadPlanFile(cmd.PlanIn)
+	util.ExitOnErr(util.InputErr(err))
+
+	if f.Api != cmd.Api {
+		util.ExitInputErr("The plan was created for API '%s' but the configuration uses API '%s'.", f.Api, cmd.Api)
+	}
+
+	if !cmd.DryRun {
+		lockContainer(cmd.Api, f.ContainerId)
+
+		fmt.Println("Check token permissions...")
+		me, err := req.GetMe(cmd.Api, cmd.Token, f.ContainerId)
+		util.ExitOnErr(err)
+		util.ExitOnErr(cli.CheckApplyPermissions(me))
+	}
+
+	// Never use the cache; the plan must match the actual remote state
+	cs := readState(cmd, f.ContainerId)
+	fingerprint, err := cs.Fingerprint()
+	util.ExitOnErr(err)
+	if fingerprint != f.Fingerprint {
+		util.ExitErrCode(util.ExitCodeConflict, "The state of container '%s' has changed since the plan was created. Please create a new plan.", cs.Container.Str())
+	}
+
+	changes = enablePlanCollectors(cmd, f.ContainerId, changes)
+	applyChanges(cmd, f.ContainerId, changes, nil)
+}
+
+// enablePlanCollectors enables the collectors of a plan and returns the
+// other changes. Creating a plan does not enable collectors, so the
+// configuration for these collectors is completed with defaults and checked
+// here, as apply does without a plan.
+func enablePlanCollectors(cmd *TApply, containerId int, changes []*Change) []*Change {
+	enable := []*Change{}
+	other := []*Change{}
+	enabled := cli.StrSet{}
+	for _, change := range changes {
+		if t, ok := change.task.(TaskSetCollectorDisplay); ok && t.display {
+			enable = append(enable, change)
+			enabled.Set(t.collectorKey)
+		} else {
+			other = append(other, change)
+		}
+	}
+	n := len(enable)
+	if n == 0 {
+		return changes
+	}
+	if cmd.DryRun {
+		util.Color("Warning: the plan enables %d collector%s; collector configurations are checked when the plan is applied.\n", n, util.Plural(n))
+		return changes
+	}
+
+	if !util.Ask(cmd.answer(nil), "To continue, %d collector%s must be enabled. Proceed? (yes/no): ", n, util.Plural(n)) {
+		util.ExitCancelled()
+	}
+	cli.ClearCache(cmd.Api, containerId) // Clear the cache as we're about to make changes
+	fmt.Println("")
+	processChanges(cmd, containerId, enable, nil)
+	fmt.Println("")
+	// We need this sleep, for the collector changes
+	time.Sleep(1 * time.Second)
+
+	fmt.Println("Read collectors...")
+	cMap := map[string]*cli.Collector{}
+	util.ExitOnErr(updateCollectorMap(cmd.Api, cmd.Token, containerId, &cMap))
+
+	// The configuration maps are shared with the tasks, so defaults are
+	// added to the tasks as well
+	ts := &cli.State{}
+	for _, change := range other {
+		if t, ok := change.task.(TaskUpsertCollectorToAsset); ok && enabled.Has(t.collectorKey) {
+			ts.Assets = append(ts.Assets, &cli.AssetCli{
+				Id:         t.asset.Id,
+				Name:       t.asset.Name,
+				Collectors: &[]cli.TCollector{{Key: t.collectorKey, Config: t.config}},
+			})
+		}
+	}
+	ensureNumbersAndDefaults(ts.Assets, cMap)
+	sanityCheckCollectorConfig(ts, cMap, cmd.Api, cmd.Token, false)
+	return other
+}
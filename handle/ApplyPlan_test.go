@@ -0,0 +1,197 @@
+package handle
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	zone := 2
+	created := &cli.AssetCli{Name: "new", Zone: &zone}
+	existing := &cli.AssetCli{Id: 11, Name: "web", Mode: "maintenance", Kind: "Linux", Zone: &zone, Description: "web server"}
+	newLabel := &cli.Label{Name: "new", Color: "red"}
+	label := &cli.Label{Id: 100, Name: "prod", Color: "blue", Description: "production"}
+	config := map[string]any{"address": "10.0.0.2"}
+
+	changes := []*Change{
+		{info: "create zone", task: TaskUpsertZone{zone: &cli.Zone{Zone: 3, Name: "dc3"}}},
+		{info: "rename zone", task: TaskUpsertZone{zone: &cli.Zone{Zone: 2, Name: "new"}, old: &cli.Zone{Zone: 2, Name: "old"}}},
+		{info: "enable collector", task: TaskSetCollectorDisplay{collectorKey: "tcp", display: true}},
+		{info: "create label", task: TaskCreateLabel{label: newLabel}},
+		{info: "set label name", task: TaskSetLabelName{label: label, old: "old"}},
+		{info: "set label color", task: TaskSetLabelColor{label: label, old: "green"}},
+		{info: "set label description", task: TaskSetLabelDescription{label: label, old: "old"}},
+		{info: "create asset", task: TaskCreateAsset{asset: created}},
+		{info: "set zone of new asset", task: TaskSetAssetZone{asset: created}},
+		{info: "add label to new asset", task: TaskAddLabelToAsset{asset: created, label: newLabel}},
+		{info: "set asset name", task: TaskSetAssetName{asset: existing, old: "old"}},
+		{info: "set asset mode", task: TaskSetAssetMode{asset: existing, old: "normal"}},
+		{info: "set asset kind", task: TaskSetAssetKind{asset: existing, old: "Windows"}},
+		{info: "set asset zone", task: TaskSetAssetZone{asset: existing, old: &zone}},
+		{info: "set asset description", task: TaskSetAssetDescription{asset: existing, old: "old"}},
+		{info: "delete label from asset", task: TaskDeleteLabelFromAsset{asset: existing, label: label}},
+		{info: "enable check", task: TaskEnableAssetCheck{asset: existing, collectorKey: "tcp", checkKey: "tcp"}},
+		{info: "disable check", task: TaskDisableAssetCheck{asset: existing, collectorKey: "ping", checkKey: "ping"}},
+		{info: "add collector", task: TaskUpsertCollectorToAsset{asset: existing, collectorKey: "tcp", config: config}},
+		{info: "update collector", task: TaskUpsertCollectorToAsset{asset: existing, collectorKey: "snmp", config: config, old: &cli.TCollector{Key: "snmp", Config: map[string]any{"address": "10.0.0.1"}}}},
+		{info: "remove collector", task: TaskRemoveCollectorFromAsset{asset: existing, collectorKey: "wmi", old: config}},
+		{info: "delete asset", task: TaskDeleteAsset{asset: &cli.AssetCli{Id: 12, Name: "old"}}, destructive: true},
+		{info: "delete label", task: TaskDeleteLabel{label: &cli.Label{Id: 101, Name: "temp"}}, destructive: true},
+	}
+
+	data, err := json.Marshal(newPlanFile("https://api.infrasonar.com", 5, "abc", changes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f TPlanFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Api != "https://api.infrasonar.com" || f.ContainerId != 5 || f.Fingerprint != "abc" {
+		t.Errorf("plan header = %q, %d, %q", f.Api, f.ContainerId, f.Fingerprint)
+	}
+	if len(got) != len(changes) {
+		t.Fatalf("got %d changes, want %d", len(got), len(changes))
+	}
+	for i, c := range changes {
+		if got[i].info != c.info || got[i].destructive != c.destructive {
+			t.Errorf("change %d: info = %q, destructive = %v", i, got[i].info, got[i].destructive)
+		}
+		if !reflect.DeepEqual(got[i].task, c.task) {
+			t.Errorf("change %d (%s): task = %#v, want %#v", i, c.info, got[i].task, c.task)
+		}
+	}
+
+	// Tasks for the same asset or label must share it after decoding, as
+	// the ID is set by the create task
+	if got[7].task.(TaskCreateAsset).asset != got[9].task.(TaskAddLabelToAsset).asset {
+		t.Error("tasks for the created asset do not share the asset")
+	}
+	if got[3].task.(TaskCreateLabel).label != got[9].task.(TaskAddLabelToAsset).label {
+		t.Error("tasks for the created label do not share the label")
+	}
+}
+
+func TestPlanFileDecodeErrors(t *testing.T) {
+	invalid := 3
+	f := &TPlanFile{
+		Assets: []*cli.AssetCli{{Id: 11}},
+		Labels: []*cli.Label{{Id: 100}},
+	}
+
+	tests := []struct {
+		name string
+		task *TPlanTask
+	}{
+		{"unknown task", &TPlanTask{Task: "SetContainerName"}},
+		{"invalid asset reference", &TPlanTask{Task: "SetAssetName", Asset: &invalid}},
+		{"invalid label reference", &TPlanTask{Task: "CreateLabel", Label: &invalid}},
+		{"missing asset", &TPlanTask{Task: "CreateAsset"}},
+		{"missing label", &TPlanTask{Task: "DeleteLabel"}},
+		{"missing zone", &TPlanTask{Task: "UpsertZone"}},
+		{"missing asset zone", &TPlanTask{Task: "SetAssetZone", Asset: new(int)}},
+		{"invalid old value", &TPlanTask{Task: "SetAssetName", Asset: new(int), Old: json.RawMessage(`{}`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := f.decode(tt.task); err == nil {
+				t.Errorf("expected an error, got %#v", c.task)
+			}
+		})
+	}
+}
+
+func TestEnablePlanCollectors(t *testing.T) {
+	enabled := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PATCH" && r.URL.Path == "/container/5/collector/tcp":
+			enabled = true
+		case r.Method == "GET" && r.URL.Path == "/container/5/collectors":
+			if !enabled {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(`[{"key": "tcp", "options": [{"key": "port", "type": "Int", "default": 80}, {"key": "timeout", "type": "Int", "default": 10}]}]`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	// Numbers in a plan are decoded as floats
+	config := map[string]any{"port": float64(443)}
+	changes := []*Change{
+		{info: "enable collector", task: TaskSetCollectorDisplay{collectorKey: "tcp", display: true}},
+		{info: "add collector", task: TaskUpsertCollectorToAsset{asset: &cli.AssetCli{Id: 11}, collectorKey: "tcp", config: config}},
+	}
+	got := enablePlanCollectors(&TApply{Api: srv.URL, Yes: true}, 5, changes)
+
+	if !enabled {
+		t.Error("collector is not enabled")
+	}
+	if len(got) != 1 || got[0] != changes[1] {
+		t.Fatalf("got %d changes, want only the collector change", len(got))
+	}
+	if want := map[string]any{"port": 443, "timeout": 10}; !reflect.DeepEqual(config, want) {
+		t.Errorf("config = %v, want %v", config, want)
+	}
+}
+
+func TestEnablePlanCollectorsCheck(t *testing.T) {
+	code, out := runExit(t, func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				w.Write([]byte(`[{"key": "tcp", "options": [{"key": "port", "type": "Int", "default": 80}]}]`))
+			}
+		}))
+		defer srv.Close()
+
+		changes := []*Change{
+			{info: "enable collector", task: TaskSetCollectorDisplay{collectorKey: "tcp", display: true}},
+			{info: "add collector", task: TaskUpsertCollectorToAsset{asset: &cli.AssetCli{Id: 11}, collectorKey: "tcp", config: map[string]any{"address": "10.0.0.1"}}},
+		}
+		enablePlanCollectors(&TApply{Api: srv.URL, Yes: true}, 5, changes)
+	})
+	if code != util.ExitCodeInput {
+		t.Errorf("exit code = %d, want %d", code, util.ExitCodeInput)
+	}
+	if !strings.Contains(out, "unknown configuration property 'address'") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestGetPlan(t *testing.T) {
+	asset := &cli.AssetCli{Id: 11, Name: "web", Mode: "maintenance"}
+	changes := []*Change{
+		{info: "set asset mode", task: TaskSetAssetMode{asset: asset, old: "normal"}},
+		{info: "add collector", task: TaskUpsertCollectorToAsset{asset: asset, collectorKey: "snmp", config: map[string]any{"address": "10.0.0.1", "password": "secret"}}},
+		{info: "delete label", task: TaskDeleteLabel{label: &cli.Label{Id: 101, Name: "temp"}}, destructive: true},
+	}
+
+	data, err := json.Marshal(getPlan(&cli.Container{Id: 5, Name: "test"}, changes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"container":{"id":5,"name":"test"},"changes":[` +
+		`{"operation":"update","targetType":"asset","targetId":11,"targetName":"web","field":"mode","oldValue":"normal","newValue":"maintenance"},` +
+		`{"operation":"add","targetType":"asset","targetId":11,"targetName":"web","field":"collector","key":"snmp","newValue":{"address":"10.0.0.1","password":"xxx"}},` +
+		`{"operation":"delete","targetType":"label","targetId":101,"targetName":"temp","destructive":true}]}`
+	if string(data) != want {
+		t.Errorf("plan = %s\nwant %s", data, want)
+	}
+}
@@ -0,0 +1,192 @@
+package handle
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+// inverse returns a change which reverts this change when it has been
+// processed, or nil if the change cannot be reverted.
+func (c *Change) inverse() *Change {
+	var task any
+
+	switch t := c.task.(type) {
+	case TaskUpsertZone:
+		if t.old == nil {
+			return nil // A zone cannot be deleted
+		}
+		task = TaskUpsertZone{zone: t.old, old: t.zone}
+	case TaskSetCollectorDisplay:
+		task = TaskSetCollectorDisplay{collectorKey: t.collectorKey, display: !t.display}
+	case TaskCreateAsset:
+		task = TaskDeleteAsset{asset: t.asset}
+	case TaskDeleteAsset:
+		return nil
+	case TaskSetAssetName:
+		asset := *t.asset
+		asset.Name = t.old
+		task = TaskSetAssetName{asset: &asset, old: t.asset.Name}
+	case TaskSetAssetMode:
+		asset := *t.asset
+		asset.Mode = t.old
+		task = TaskSetAssetMode{asset: &asset, old: t.asset.Mode}
+	case TaskSetAssetKind:
+		asset := *t.asset
+		asset.Kind = t.old
+		task = TaskSetAssetKind{asset: &asset, old: t.asset.Kind}
+	case TaskSetAssetZone:
+		asset := *t.asset
+		asset.Zone = t.old
+		if asset.Zone == nil {
+			asset.Zone = &cli.DefaultZone
+		}
+		task = TaskSetAssetZone{asset: &asset, old: t.asset.Zone}
+	case TaskSetAssetDescription:
+		asset := *t.asset
+		asset.Description = t.old
+		task = TaskSetAssetDescription{asset: &asset, old: t.asset.Description}
+	case TaskAddLabelToAsset:
+		task = TaskDeleteLabelFromAsset(t)
+	case TaskDeleteLabelFromAsset:
+		task = TaskAddLabelToAsset(t)
+	case TaskEnableAssetCheck:
+		task = TaskDisableAssetCheck(t)
+	case TaskDisableAssetCheck:
+		task = TaskEnableAssetCheck(t)
+	case TaskUpsertCollectorToAsset:
+		if t.old == nil {
+			task = TaskRemoveCollectorFromAsset{asset: t.asset, collectorKey: t.collectorKey, old: t.config}
+		} else {
+			task = TaskUpsertCollectorToAsset{asset: t.asset, collectorKey: t.collectorKey, config: t.old.Config, old: &cli.TCollector{Key: t.collectorKey, Config: t.config}}
+		}
+	case TaskRemoveCollectorFromAsset:
+		task = TaskUpsertCollectorToAsset{asset: t.asset, collectorKey: t.collectorKey, config: t.old}
+	case TaskCreateLabel:
+		task = TaskDeleteLabel{label: t.label}
+	case TaskDeleteLabel:
+		return nil
+	case TaskSetLabelName:
+		label := *t.label
+		label.Name = t.old
+		task = TaskSetLabelName{label: &label, old: t.label.Name}
+	case TaskSetLabelColor:
+		label := *t.label
+		label.Color = t.old
+		task = TaskSetLabelColor{label: &label, old: t.label.Color}
+	case TaskSetLabelDescription:
+		label := *t.label
+		label.Description = t.old
+		task = TaskSetLabelDescription{label: &label, old: t.label.Description}
+	default:
+		return nil
+	}
+
+	return &Change{
+		info: fmt.Sprintf("Undo: %s", c.info),
+		task: task,
+	}
+}
+
+// createdBy returns the asset or label which is created by the change.
+func createdBy(c *Change) any {
+	switch t := c.task.(type) {
+	case TaskCreateAsset:
+		return t.asset
+	case TaskCreateLabel:
+		return t.label
+	}
+	return nil
+}
+
+// dependsOn returns true if the change modifies an asset or label which is
+// in the created set. Such changes do not need to be reverted as the asset or
+// label itself will be deleted.
+func dependsOn(c *Change, created map[any]bool) bool {
+	switch t := c.task.(type) {
+	case TaskSetAssetName:
+		return created[t.asset]
+	case TaskSetAssetMode:
+		return created[t.asset]
+	case TaskSetAssetKind:
+		return created[t.asset]
+	case TaskSetAssetZone:
+		return created[t.asset]
+	case TaskSetAssetDescription:
+		return created[t.asset]
+	case TaskAddLabelToAsset:
+		return created[t.asset] || created[t.label]
+	case TaskEnableAssetCheck:
+		return created[t.asset]
+	case TaskDisableAssetCheck:
+		return created[t.asset]
+	case TaskUpsertCollectorToAsset:
+		return created[t.asset]
+	case TaskSetLabelName:
+		return created[t.label]
+	case TaskSetLabelColor:
+		return created[t.label]
+	case TaskSetLabelDescription:
+		return created[t.label]
+	}
+	return false
+}
+
+func countIrreversible(changes []*Change) int {
+	n := 0
+	for _, c := range changes {
+		if c.inverse() == nil {
+			n += 1
+		}
+	}
+	return n
+}
+
+// rollback reverts the given processed changes in reverse order.
+func rollback(api, token string, containerId int, done []*Change) {
+	created := map[any]bool{}
+	for _, c := range done {
+		if obj := createdBy(c); obj != nil {
+			created[obj] = true
+		}
+	}
+
+	undo := []*Change{}
+	failed := []*Change{}
+	for i := len(done) - 1; i >= 0; i-- {
+		c := done[i]
+		if dependsOn(c, created) {
+			continue
+		}
+		if inv := c.inverse(); inv != nil {
+			undo = append(undo, inv)
+		} else {
+			failed = append(failed, c)
+		}
+	}
+
+	n := len(undo)
+	fmt.Println("")
+	fmt.Println(color.HiYellowString("Rolling back %d task%s...", n, util.Plural(n)))
+	for i, c := range undo {
+		fmt.Printf("Processing rollback task %d/%d: %s ...\n", i+1, n, c.info)
+		if err := processTask(api, token, containerId, c); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = append(failed, c)
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, color.HiRedString("The following changes could not be rolled back:"))
+		fmt.Fprintln(os.Stderr, "")
+		for _, c := range failed {
+			fmt.Fprintf(os.Stderr, "- %s\n", c.info)
+		}
+	} else {
+		fmt.Println("Rollback completed.")
+	}
+}
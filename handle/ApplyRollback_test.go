@@ -0,0 +1,135 @@
+package handle
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+// recordServer returns a test server which records all requests as
+// "<method> <path> <body>", and writes them to w when not nil. Requests for
+// which fail returns true get status 422.
+func recordServer(w io.Writer, fail func(r *http.Request) bool) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	requests := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		request := strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+		mu.Lock()
+		requests = append(requests, request)
+		if w != nil {
+			fmt.Fprintf(w, "request: %s\n", request)
+		}
+		mu.Unlock()
+		switch {
+		case fail != nil && fail(r):
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+		case r.URL.Path == "/container/5/label":
+			rw.Write([]byte(`{"labelId": 200}`))
+		case r.URL.Path == "/container/5/asset":
+			rw.Write([]byte(`{"assetId": 21}`))
+		}
+	}))
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(requests)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	srv, requests := recordServer(nil, nil)
+	defer srv.Close()
+
+	createdLabel := &cli.Label{Id: 200, Name: "new"}
+	createdAsset := &cli.AssetCli{Id: 21, Name: "new"}
+	existing := &cli.AssetCli{Id: 11, Name: "web", Mode: "maintenance"}
+	label := &cli.Label{Id: 100, Name: "prod"}
+	done := []*Change{
+		{info: "create label", task: TaskCreateLabel{label: createdLabel}},
+		{info: "create asset", task: TaskCreateAsset{asset: createdAsset}},
+		{info: "set name of created asset", task: TaskSetAssetName{asset: createdAsset, old: ""}},
+		{info: "add created label", task: TaskAddLabelToAsset{asset: existing, label: createdLabel}},
+		{info: "set mode", task: TaskSetAssetMode{asset: existing, old: "normal"}},
+		{info: "delete label from asset", task: TaskDeleteLabelFromAsset{asset: existing, label: label}},
+		{info: "delete asset", task: TaskDeleteAsset{asset: &cli.AssetCli{Id: 12}}, destructive: true},
+	}
+	rollback(srv.URL, "token", 5, done)
+
+	// In reverse order; changes of created assets and labels are not
+	// reverted as these are deleted, and a deleted asset cannot be restored
+	want := []string{
+		"PUT /asset/11/label/100",
+		`PATCH /asset/11/mode {"mode":"normal"}`,
+		"DELETE /asset/21",
+		"DELETE /label/200",
+	}
+	if got := requests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+	if existing.Mode != "maintenance" {
+		t.Errorf("mode of the asset in the change changed to %q", existing.Mode)
+	}
+}
+
+func TestAtomicApplyRollsBack(t *testing.T) {
+	for _, parallel := range []int{1, 4} {
+		t.Run(fmt.Sprintf("parallel %d", parallel), func(t *testing.T) {
+			code, out := runExit(t, func() {
+				// The process exits after the rollback, so requests are
+				// written to the output as they are made
+				srv, _ := recordServer(os.Stdout, func(r *http.Request) bool {
+					return r.URL.Path == "/asset/11/collector/tcp"
+				})
+				defer srv.Close()
+
+				asset := &cli.AssetCli{Id: 11, Name: "web"}
+				changes := []*Change{
+					{info: "create label", task: TaskCreateLabel{label: &cli.Label{Name: "new"}}},
+					{info: "set asset name", task: TaskSetAssetName{asset: asset, old: "old"}},
+					{info: "add collector", task: TaskUpsertCollectorToAsset{asset: asset, collectorKey: "tcp", config: map[string]any{}}},
+				}
+				processChanges(&TApply{Api: srv.URL, Atomic: true, Parallel: parallel}, 5, changes, nil)
+			})
+			if code != util.ExitCodeValidation {
+				t.Errorf("exit code = %d, want %d", code, util.ExitCodeValidation)
+			}
+
+			got := []string{}
+			for _, line := range strings.Split(out, "\n") {
+				if request, ok := strings.CutPrefix(line, "request: "); ok {
+					got = append(got, request)
+				}
+			}
+			want := []string{
+				`POST /container/5/label {"name":"new"}`,
+				`PATCH /asset/11/name {"name":"web"}`,
+				"POST /asset/11/collector/tcp {}",
+				`PATCH /asset/11/name {"name":"old"}`,
+				"DELETE /label/200",
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("requests = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestInverseDoesNotModifyTask(t *testing.T) {
+	asset := &cli.AssetCli{Id: 11, Name: "new"}
+	c := &Change{task: TaskSetAssetName{asset: asset, old: "old"}}
+	c.inverse()
+	if asset.Name != "new" {
+		t.Errorf("asset name changed to %q", asset.Name)
+	}
+}
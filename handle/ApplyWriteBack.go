@@ -0,0 +1,197 @@
+package handle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"gopkg.in/yaml.v3"
+)
+
+// writeBack inserts the IDs of new assets and labels into the input file.
+// The file is edited as text at the positions found by yaml.v3, so comments,
+// key order and formatting are preserved. JSON is parsed as YAML.
+type writeBack struct {
+	fn      string
+	isJson  bool
+	content []byte
+	assets  map[int]*cli.AssetCli // Assets without an ID, by index in the file
+	labels  map[string]*cli.Label // Labels without an ID, by key
+}
+
+// textEdit replaces n bytes at offset with text.
+type textEdit struct {
+	offset int
+	n      int
+	text   string
+}
+
+func newWriteBack(fn string, ts *cli.State) (*writeBack, error) {
+	content, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %s", fn, err)
+	}
+	ext, err := cli.GetJsonOrYaml(fn)
+	if err != nil {
+		return nil, err
+	}
+	w := &writeBack{
+		fn:      fn,
+		isJson:  ext == "json",
+		content: content,
+		assets:  map[int]*cli.AssetCli{},
+		labels:  map[string]*cli.Label{},
+	}
+	for i, ta := range ts.Assets {
+		if ta.Id == 0 {
+			w.assets[i] = ta
+		}
+	}
+	for key, tl := range ts.Labels {
+		if tl.Id == 0 {
+			w.labels[key] = tl
+		}
+	}
+	return w, nil
+}
+
+// mappingValue returns the value for a key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// offset returns the byte offset for a line and column, both starting at 1.
+// A column counts characters, not bytes.
+func offset(content []byte, line, column int) int {
+	pos := 0
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(content[pos:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		pos += i + 1
+	}
+	for c := 1; c < column && pos < len(content); c++ {
+		_, size := utf8.DecodeRune(content[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// setId returns the edit which sets the id in an asset or label mapping.
+func (w *writeBack) setId(node *yaml.Node, id int) (*textEdit, error) {
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return nil, fmt.Errorf("unexpected %s at line %d", node.Tag, node.Line)
+	}
+	if _, value := mappingValue(node, "id"); value != nil {
+		// Replace an existing zero ID
+		n := len(value.Value)
+		if value.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+			n += 2
+		}
+		return &textEdit{
+			offset: offset(w.content, value.Line, value.Column),
+			n:      n,
+			text:   fmt.Sprint(id),
+		}, nil
+	}
+
+	first := node.Content[0]
+	key := "id"
+	if w.isJson {
+		key = `"id"`
+	}
+	var text string
+	switch {
+	case node.Style&yaml.FlowStyle == 0:
+		text = fmt.Sprintf("%s: %d\n%s", key, id, strings.Repeat(" ", first.Column-1))
+	case first.Line > node.Line:
+		text = fmt.Sprintf("%s: %d,\n%s", key, id, strings.Repeat(" ", first.Column-1))
+	default:
+		text = fmt.Sprintf("%s: %d, ", key, id)
+	}
+	return &textEdit{
+		offset: offset(w.content, first.Line, first.Column),
+		text:   text,
+	}, nil
+}
+
+// write updates the input file with the IDs which are set since the file was
+// read. It returns the number of IDs written.
+func (w *writeBack) write() (int, error) {
+	current, err := os.ReadFile(w.fn)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read '%s': %s", w.fn, err)
+	}
+	if !bytes.Equal(current, w.content) {
+		return 0, fmt.Errorf("'%s' was changed during apply", w.fn)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(w.content, &doc); err != nil {
+		return 0, fmt.Errorf("failed to parse '%s': %s", w.fn, err)
+	}
+	if len(doc.Content) == 0 {
+		return 0, errors.New("empty document")
+	}
+	root := doc.Content[0]
+
+	edits := []*textEdit{}
+	_, assets := mappingValue(root, "assets")
+	for i, ta := range w.assets {
+		if ta.Id == 0 || assets == nil || i >= len(assets.Content) {
+			continue
+		}
+		edit, err := w.setId(assets.Content[i], ta.Id)
+		if err != nil {
+			return 0, err
+		}
+		edits = append(edits, edit)
+	}
+	_, labels := mappingValue(root, "labels")
+	for key, tl := range w.labels {
+		if tl.Id == 0 {
+			continue
+		}
+		if _, label := mappingValue(labels, key); label != nil {
+			edit, err := w.setId(label, tl.Id)
+			if err != nil {
+				return 0, err
+			}
+			edits = append(edits, edit)
+		}
+	}
+	if len(edits) == 0 {
+		return 0, nil
+	}
+
+	// Apply from the end of the file so offsets remain valid
+	slices.SortFunc(edits, func(a, b *textEdit) int { return b.offset - a.offset })
+	content := slices.Clone(w.content)
+	for _, e := range edits {
+		content = slices.Concat(content[:e.offset], []byte(e.text), content[e.offset+e.n:])
+	}
+
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(w.fn); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	if err := cli.WriteFileAtomic(w.fn, content, perm); err != nil {
+		return 0, fmt.Errorf("failed to write '%s': %s", w.fn, err)
+	}
+	w.content = content
+	return len(edits), nil
+}
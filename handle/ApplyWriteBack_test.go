@@ -0,0 +1,223 @@
+package handle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+)
+
+func TestWriteBack(t *testing.T) {
+	tests := []struct {
+		name    string
+		ext     string
+		content string
+		want    string
+	}{
+		{
+			name: "yaml block",
+			ext:  "yaml",
+			content: `# Onboarding
+container:
+  id: 5
+labels:
+  new:
+    name: New  # created
+  prod:
+    id: 100
+assets:
+  - name: web1
+    kind: Linux
+  - id: 11
+    name: web2
+  - id: 0
+    name: web3
+`,
+			want: `# Onboarding
+container:
+  id: 5
+labels:
+  new:
+    id: 101
+    name: New  # created
+  prod:
+    id: 100
+assets:
+  - id: 21
+    name: web1
+    kind: Linux
+  - id: 11
+    name: web2
+  - id: 23
+    name: web3
+`,
+		},
+		{
+			name: "yaml flow",
+			ext:  "yml",
+			content: `container: {id: 5}
+labels:
+  new: {name: New}
+  prod: {id: 100}
+assets:
+  - {name: web1, kind: Linux}
+  - {id: 11, name: web2}
+  - {id: 0, name: web3}
+`,
+			want: `container: {id: 5}
+labels:
+  new: {id: 101, name: New}
+  prod: {id: 100}
+assets:
+  - {id: 21, name: web1, kind: Linux}
+  - {id: 11, name: web2}
+  - {id: 23, name: web3}
+`,
+		},
+		{
+			name: "json",
+			ext:  "json",
+			content: `{
+  "container": {"id": 5},
+  "labels": {
+    "new": {
+      "name": "New"
+    },
+    "prod": {"id": 100}
+  },
+  "assets": [
+    {
+      "name": "web1",
+      "kind": "Linux"
+    },
+    {"id": 11, "name": "web2"},
+    {"id": 0, "name": "web3"}
+  ]
+}
+`,
+			want: `{
+  "container": {"id": 5},
+  "labels": {
+    "new": {
+      "id": 101,
+      "name": "New"
+    },
+    "prod": {"id": 100}
+  },
+  "assets": [
+    {
+      "id": 21,
+      "name": "web1",
+      "kind": "Linux"
+    },
+    {"id": 11, "name": "web2"},
+    {"id": 23, "name": "web3"}
+  ]
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "input."+tt.ext)
+			if err := os.WriteFile(fn, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ts, err := cli.StateFromFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := newWriteBack(fn, ts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Set by the create tasks
+			ts.Assets[0].Id = 21
+			ts.Assets[2].Id = 23
+			ts.Labels["new"].Id = 101
+
+			n, err := w.write()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 3 {
+				t.Errorf("wrote %d IDs, want 3", n)
+			}
+			got, err := os.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("content =\n%s\nwant\n%s", got, tt.want)
+			}
+			if _, err := cli.StateFromFile(fn); err != nil {
+				t.Errorf("result cannot be read: %s", err)
+			}
+		})
+	}
+}
+
+func TestWriteBackWithoutNewIds(t *testing.T) {
+	content := "container:\n  id: 5\nassets:\n  - name: web1\n"
+	fn := filepath.Join(t.TempDir(), "input.yaml")
+	if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := cli.StateFromFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newWriteBack(fn, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.write(); err != nil || n != 0 {
+		t.Errorf("write = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestWriteBackChangedFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "input.yaml")
+	if err := os.WriteFile(fn, []byte("container:\n  id: 5\nassets:\n  - name: web1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := cli.StateFromFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newWriteBack(fn, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fn, []byte("container:\n  id: 6\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts.Assets[0].Id = 21
+	if _, err := w.write(); err == nil {
+		t.Error("expected an error for a changed file")
+	}
+}
+
+func TestOffset(t *testing.T) {
+	content := []byte("ab\nçd\n")
+
+	tests := []struct {
+		line, column, want int
+	}{
+		{1, 1, 0},
+		{1, 3, 2},
+		{2, 1, 3},
+		{2, 2, 5}, // ç is two bytes
+		{3, 1, 7},
+		{9, 1, 7},
+	}
+
+	for _, tt := range tests {
+		if got := offset(content, tt.line, tt.column); got != tt.want {
+			t.Errorf("offset(%d, %d) = %d, want %d", tt.line, tt.column, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package handle
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+// runExit runs fn in a new process of the test binary as fn may exit. It
+// returns the exit code and the output of the process.
+func runExit(t *testing.T, fn func()) (int, string) {
+	t.Helper()
+	if os.Getenv("TEST_RUN_EXIT") == t.Name() {
+		fn()
+		os.Exit(0)
+	}
+
+	parts := strings.Split(t.Name(), "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	cmd := exec.Command(os.Args[0], "-test.run="+strings.Join(parts, "/"))
+	cmd.Env = append(os.Environ(), "TEST_RUN_EXIT="+t.Name())
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), string(out)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return 0, string(out)
+}
+
+func testStates() (cs, ts *cli.State) {
+	container := &cli.Container{Id: 5, Name: "test"}
+	cs = &cli.State{
+		Container: container,
+		Assets:    []*cli.AssetCli{{Id: 11, Name: "web1"}},
+	}
+	ts = &cli.State{Container: container}
+	return cs, ts
+}
+
+func TestEnsureChangesAbsentAsset(t *testing.T) {
+	cs, ts := testStates()
+	ts.Assets = []*cli.AssetCli{
+		{Id: 11, Presence: cli.Presence{State: cli.StateAbsent}},
+		{Id: 12, Presence: cli.Presence{Remove: true}}, // Already absent
+	}
+	changes := ensureChanges(&TApply{}, cs, ts, map[string]*cli.Collector{})
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	task, ok := changes[0].task.(TaskDeleteAsset)
+	if !ok || task.asset.Id != 11 || !changes[0].destructive {
+		t.Errorf("change = %s, want a destructive delete of asset 11", changes[0].info)
+	}
+}
+
+func TestEnsureChangesAbsentAssetWithoutId(t *testing.T) {
+	t.Run("without match-by", func(t *testing.T) {
+		code, out := runExit(t, func() {
+			cs, ts := testStates()
+			ts.Assets = []*cli.AssetCli{{Name: "web1", Presence: cli.Presence{State: cli.StateAbsent}}}
+			ensureChanges(&TApply{}, cs, ts, map[string]*cli.Collector{})
+		})
+		if code != util.ExitCodeInput {
+			t.Errorf("exit code = %d, want %d", code, util.ExitCodeInput)
+		}
+		if !strings.Contains(out, "is marked as absent but has no 'id'") {
+			t.Errorf("unexpected output %q", out)
+		}
+	})
+	t.Run("not matched", func(t *testing.T) {
+		cs, ts := testStates()
+		ts.Assets = []*cli.AssetCli{{Name: "web2", Presence: cli.Presence{State: cli.StateAbsent}}}
+		matchAssets(cs, ts, matchByName)
+		if changes := ensureChanges(&TApply{MatchBy: matchByName}, cs, ts, map[string]*cli.Collector{}); len(changes) != 0 {
+			t.Errorf("got %d changes, want none", len(changes))
+		}
+	})
+}
+
+func TestCheckPresenceAbsentProperty(t *testing.T) {
+	code, out := runExit(t, func() {
+		checkPresence(&cli.AssetCli{
+			Id:         11,
+			Properties: &[]cli.TProperty{{Key: "rack", Presence: cli.Presence{State: cli.StateAbsent}}},
+		})
+	})
+	if code != util.ExitCodeInput {
+		t.Errorf("exit code = %d, want %d", code, util.ExitCodeInput)
+	}
+	if !strings.Contains(out, "removing properties is not supported") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
@@ -0,0 +1,57 @@
+package handle
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+func CacheList() {
+	caches, err := cli.ListCache()
+	util.ExitOnErr(err)
+
+	mHost := 8
+	for _, c := range caches {
+		if len(c.Host) > mHost {
+			mHost = len(c.Host)
+		}
+	}
+	fmt.Printf("%-*s    %-9s    %-13s    %s\n", mHost, "API HOST", "CONTAINER", "AGE", "SIZE")
+	for _, c := range caches {
+		host := c.Host
+		if host == "" {
+			host = "-" // Cache of an older version
+		}
+		age := util.HumanizeDuration(time.Since(c.ModTime))
+		fmt.Printf("%-*s    %-9d    %-13s    %d\n", mHost, host, c.ContainerId, age, c.Size)
+	}
+	os.Exit(0)
+}
+
+func CacheShow(api string, containerId int, output string) {
+	state := cli.StateFromCache(api, containerId)
+	if state == nil {
+		util.ExitErrCode(util.ExitCodeNotFound, "no cache found for container ID %d", containerId)
+	}
+	util.ExitOutput(state, output, "")
+}
+
+// CacheClear removes the cache files for a container, or all cache files
+// when containerId is 0.
+func CacheClear(api string, containerId int) {
+	caches, err := cli.ListCache()
+	util.ExitOnErr(err)
+
+	n := 0
+	for _, c := range caches {
+		if containerId != 0 && (c.ContainerId != containerId || !c.Matches(api)) {
+			continue
+		}
+		util.ExitOnErr(os.Remove(c.Fn))
+		n += 1
+	}
+	util.ExitOk("Removed %d cache file%s", n, util.Plural(n))
+}
@@ -12,7 +12,7 @@ func ConfigDelete(name string) {
 	config := conf.EnsureConfig(name)
 	conf.Delete(config)
 	if err := conf.Write(); err != nil {
-		util.ExitErr("failed to write changes")
+		util.ExitErrFor(err, "failed to write changes (%s)", err)
 	}
 	util.ExitOk("Configuration removed")
 }
@@ -3,6 +3,7 @@ package handle
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/infrasonar/infrasonar-cli/conf"
 )
@@ -15,9 +16,13 @@ func ConfigList(more bool) {
 				mName = len(config.Name)
 			}
 		}
-		fmt.Printf("%-*s    %-6s    %s\n", mName, "NAME", "OUTPUT", "API")
+		fmt.Printf("%-*s    %-6s    %-9s    %s\n", mName, "NAME", "OUTPUT", "CONTAINER", "API")
 		for _, config := range conf.GetConfigs() {
-			fmt.Printf("%-*s    %-6s    %s\n", mName, config.Name, config.Output, config.Api)
+			container := ""
+			if config.Container != 0 {
+				container = strconv.Itoa(config.Container)
+			}
+			fmt.Printf("%-*s    %-6s    %-9s    %s\n", mName, config.Name, config.Output, container, config.Api)
 		}
 
 	} else {
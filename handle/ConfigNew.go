@@ -11,6 +11,17 @@ type TConfigNew struct {
 	Api        string
 	Output     string
 	SetDefault bool
+	Retries    *int
+	Timeout    *int
+	Container  *int
+	CacheTtl   *int
+	RateLimit  *float64
+
+	Proxy              *string
+	CaBundle           *string
+	ClientCert         *string
+	ClientKey          *string
+	InsecureSkipVerify *bool
 }
 
 func ConfigNew(cmd *TConfigNew) {
@@ -20,10 +31,27 @@ func ConfigNew(cmd *TConfigNew) {
 	if cmd.Token == "" {
 		cmd.Token = util.AskToken()
 	}
+
+	// Validate the proxy and TLS settings before the configuration is created
+	var check conf.Config
+	setConnectionSettings(&check, cmd.Proxy, cmd.CaBundle, cmd.ClientCert, cmd.ClientKey, cmd.InsecureSkipVerify)
+	util.ExitOnErr(util.InputErr(check.RequestSettings().Validate()))
+
 	config, err := conf.New(cmd.Name, cmd.Token, cmd.Api, cmd.Output)
+	util.ExitOnErr(err)
+	config.Retries = cmd.Retries
+	config.Timeout = cmd.Timeout
+	config.CacheTtl = cmd.CacheTtl
+	if cmd.Container != nil {
+		config.Container = *cmd.Container
+	}
+	if cmd.RateLimit != nil {
+		config.RateLimit = *cmd.RateLimit
+	}
+	setConnectionSettings(config, cmd.Proxy, cmd.CaBundle, cmd.ClientCert, cmd.ClientKey, cmd.InsecureSkipVerify)
 	if cmd.SetDefault {
 		conf.SetDefault(config)
 	}
-	util.ExitOnErr(err)
+	util.ExitOnErr(conf.Write())
 	util.ExitOk("Configuration '%s' created\n", config.Name)
 }
@@ -0,0 +1,17 @@
+package handle
+
+import (
+	"github.com/infrasonar/infrasonar-cli/conf"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+)
+
+func ConfigRekey(method, keyFile string) {
+	if method == conf.KeyFile && keyFile == "" {
+		util.ExitInputErr("a key file is required for the key-file method (--key-file)")
+	}
+	if method != conf.KeyFile && keyFile != "" {
+		util.ExitInputErr("--key-file is only valid with the key-file method")
+	}
+	util.ExitOnErr(conf.Rekey(method, keyFile))
+	util.ExitOk("Tokens encrypted with the new key")
+}
@@ -0,0 +1,60 @@
+package handle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+	"github.com/infrasonar/infrasonar-cli/infrasonar"
+	"github.com/infrasonar/infrasonar-cli/req"
+)
+
+type TConfigTest struct {
+	Name      string
+	Api       string
+	Token     string
+	Container string
+}
+
+func ConfigTest(cmd *TConfigTest) {
+	fmt.Printf("Configuration: %s\n", cmd.Name)
+	fmt.Printf("API:           %s\n", cmd.Api)
+
+	start := time.Now()
+	containerId, err := req.GetContainerId(cmd.Api, cmd.Token)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil && !util.IsUserTokenErr(err) {
+		var apiErr *infrasonar.APIError
+		if errors.As(err, &apiErr) {
+			fmt.Printf("Reachable:     yes (%s)\n", latency)
+		} else {
+			fmt.Println("Reachable:     no")
+		}
+		util.ExitOnErr(err)
+	}
+	fmt.Printf("Reachable:     yes (%s)\n", latency)
+
+	if cmd.Container == "" {
+		if containerId == 0 {
+			fmt.Println("Token type:    user")
+			util.ExitOk("Container:     none; use --container or a default container to check the permissions")
+		}
+		cmd.Container = fmt.Sprint(containerId)
+	}
+	container := util.EnsureContainer(cmd.Api, cmd.Token, cmd.Container)
+	me, err := req.GetMe(cmd.Api, cmd.Token, container.Id)
+	util.ExitOnErr(err)
+
+	fmt.Printf("Token type:    %s\n", me.TokenType)
+	fmt.Printf("Container:     %s (ID %d)\n", container.Name, container.Id)
+	if missing := cli.MissingApplyPermissions(me); len(missing) > 0 {
+		util.Color("Apply:         missing permissions %s\n", strings.Join(missing, ", "))
+	} else {
+		fmt.Println("Apply:         OK")
+	}
+	os.Exit(util.ExitCodeOk)
+}
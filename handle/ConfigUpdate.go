@@ -3,6 +3,7 @@ package handle
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/infrasonar/infrasonar-cli/conf"
 	"github.com/infrasonar/infrasonar-cli/handle/util"
@@ -14,6 +15,17 @@ type TConfigUpdate struct {
 	Api        string
 	Output     string
 	SetDefault bool
+	Retries    *int
+	Timeout    *int
+	Container  *int
+	CacheTtl   *int
+	RateLimit  *float64
+
+	Proxy              *string
+	CaBundle           *string
+	ClientCert         *string
+	ClientKey          *string
+	InsecureSkipVerify *bool
 }
 
 func ConfigUpdate(cmd *TConfigUpdate) {
@@ -28,21 +40,44 @@ func ConfigUpdate(cmd *TConfigUpdate) {
 		isChanged = true
 	}
 	if cmd.Token != "" {
-		config.EncToken = cmd.Output
+		util.ExitOnErr(config.SetToken(cmd.Token))
 		isChanged = true
 	}
 	if cmd.Output != "" && config.Output != cmd.Output {
 		config.Output = cmd.Output
 		isChanged = true
 	}
+	if cmd.Retries != nil && (config.Retries == nil || *config.Retries != *cmd.Retries) {
+		config.Retries = cmd.Retries
+		isChanged = true
+	}
+	if cmd.Timeout != nil && (config.Timeout == nil || *config.Timeout != *cmd.Timeout) {
+		config.Timeout = cmd.Timeout
+		isChanged = true
+	}
+	if cmd.CacheTtl != nil && (config.CacheTtl == nil || *config.CacheTtl != *cmd.CacheTtl) {
+		config.CacheTtl = cmd.CacheTtl
+		isChanged = true
+	}
+	if cmd.Container != nil && config.Container != *cmd.Container {
+		config.Container = *cmd.Container
+		isChanged = true
+	}
+	if cmd.RateLimit != nil && config.RateLimit != *cmd.RateLimit {
+		config.RateLimit = *cmd.RateLimit
+		isChanged = true
+	}
+	if setConnectionSettings(config, cmd.Proxy, cmd.CaBundle, cmd.ClientCert, cmd.ClientKey, cmd.InsecureSkipVerify) {
+		util.ExitOnErr(util.InputErr(config.RequestSettings().Validate()))
+		isChanged = true
+	}
 	if cmd.SetDefault && config != conf.Def() {
 		conf.SetDefault(config)
 		isChanged = true
 	}
 	if isChanged {
 		if err := conf.Write(); err != nil {
-			fmt.Fprintln(os.Stderr, "failed to write changes")
-			os.Exit(1)
+			util.ExitErrFor(err, "failed to write changes (%s)", err)
 		}
 		fmt.Println("Changes written")
 	} else {
@@ -50,3 +85,34 @@ func ConfigUpdate(cmd *TConfigUpdate) {
 	}
 	os.Exit(0)
 }
+
+// setConnectionSettings sets the proxy and TLS settings which are not nil.
+// An empty string removes the setting. File names are stored as absolute
+// paths. Returns true if a setting is changed.
+func setConnectionSettings(config *conf.Config, proxy, caBundle, clientCert, clientKey *string, insecureSkipVerify *bool) bool {
+	isChanged := false
+	set := func(target *string, value *string, isFile bool) {
+		if value == nil {
+			return
+		}
+		v := *value
+		if isFile && v != "" {
+			if abs, err := filepath.Abs(v); err == nil {
+				v = abs
+			}
+		}
+		if *target != v {
+			*target = v
+			isChanged = true
+		}
+	}
+	set(&config.Proxy, proxy, false)
+	set(&config.CaBundle, caBundle, true)
+	set(&config.ClientCert, clientCert, true)
+	set(&config.ClientKey, clientKey, true)
+	if insecureSkipVerify != nil && config.InsecureSkipVerify != *insecureSkipVerify {
+		config.InsecureSkipVerify = *insecureSkipVerify
+		isChanged = true
+	}
+	return isChanged
+}
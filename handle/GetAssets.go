@@ -13,7 +13,7 @@ type TGetAssets struct {
 	Token           string
 	Output          string
 	OutFn           string
-	Container       int
+	Container       string
 	Asset           int
 	Properties      []string
 	Filters         []string
@@ -90,6 +90,18 @@ func getAssetsCli(assets []*cli.AssetApi, labelMap *cli.LabelMap) []*cli.AssetCl
 		for _, labelId := range a.Labels {
 			labels = append(labels, labelMap.GetName(labelId))
 		}
+		collectors := make([]cli.TCollector, len(a.Collectors))
+		for i, c := range a.Collectors {
+			collectors[i] = cli.TCollector{Key: c.Key, Config: c.Config}
+		}
+		disabledChecks := make([]cli.TDisabledChecks, len(a.DisabledChecks))
+		for i, c := range a.DisabledChecks {
+			disabledChecks[i] = cli.TDisabledChecks{Collector: c.Collector, Check: c.Check}
+		}
+		properties := make([]cli.TProperty, len(a.Properties))
+		for i, p := range a.Properties {
+			properties[i] = cli.TProperty{Key: p.Key, Value: p.Value}
+		}
 		asset := cli.AssetCli{
 			Id:             a.Id,
 			Name:           a.Name,
@@ -98,9 +110,9 @@ func getAssetsCli(assets []*cli.AssetApi, labelMap *cli.LabelMap) []*cli.AssetCl
 			Description:    a.Description,
 			Mode:           a.Mode,
 			Kind:           a.Kind,
-			Collectors:     &a.Collectors,
-			DisabledChecks: &a.DisabledChecks,
-			Properties:     &a.Properties,
+			Collectors:     &collectors,
+			DisabledChecks: &disabledChecks,
+			Properties:     &properties,
 		}
 		if len(*asset.Labels) == 0 {
 			asset.Labels = nil
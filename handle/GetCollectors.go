@@ -1,9 +1,6 @@
 package handle
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/infrasonar/infrasonar-cli/cli"
 	"github.com/infrasonar/infrasonar-cli/handle/util"
 	"github.com/infrasonar/infrasonar-cli/req"
@@ -14,7 +11,7 @@ type TGetCollectors struct {
 	Token      string
 	Output     string
 	OutFn      string
-	Container  int
+	Container  string
 	Properties []string
 	Collector  string
 }
@@ -56,8 +53,7 @@ func GetCollectors(cmd *TGetCollectors) {
 			}
 		}
 		if len(out) == 0 {
-			fmt.Fprintf(os.Stderr, "collector '%s' not found\n", cmd.Collector)
-			os.Exit(1)
+			util.ExitErrCode(util.ExitCodeNotFound, "collector '%s' not found", cmd.Collector)
 		}
 		collectors = out
 	}
@@ -13,7 +13,7 @@ type TGetMe struct {
 	Token      string
 	Output     string
 	OutFn      string
-	Container  int
+	Container  string
 	Properties []string
 }
 
@@ -0,0 +1,61 @@
+package handle
+
+import (
+	"fmt"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/handle/util"
+	"github.com/infrasonar/infrasonar-cli/req"
+)
+
+type TWhoami struct {
+	Config    string
+	Api       string
+	Token     string
+	Output    string
+	Container string
+}
+
+type TWhoamiOut struct {
+	Config    string         `json:"config" yaml:"config"`
+	Api       string         `json:"api" yaml:"api"`
+	Container *cli.Container `json:"container" yaml:"container"`
+	TokenType string         `json:"tokenType" yaml:"tokenType"`
+}
+
+func (o *TWhoamiOut) Out() any {
+	container := "none"
+	if o.Container != nil {
+		container = fmt.Sprintf("%s (ID %d)", o.Container.Name, o.Container.Id)
+	}
+	return []string{
+		fmt.Sprintf("Configuration: %s", o.Config),
+		fmt.Sprintf("API:           %s", o.Api),
+		fmt.Sprintf("Container:     %s", container),
+		fmt.Sprintf("Token type:    %s", o.TokenType),
+	}
+}
+
+func Whoami(cmd *TWhoami) {
+	out := TWhoamiOut{
+		Config: cmd.Config,
+		Api:    cmd.Api,
+	}
+
+	if cmd.Container == "" {
+		containerId, err := req.GetContainerId(cmd.Api, cmd.Token)
+		if util.IsUserTokenErr(err) {
+			// Without a container, the token type cannot be requested
+			out.TokenType = "user"
+			util.ExitOutput(&out, cmd.Output, "")
+		}
+		util.ExitOnErr(err)
+		cmd.Container = fmt.Sprint(containerId)
+	}
+
+	out.Container = util.EnsureContainer(cmd.Api, cmd.Token, cmd.Container)
+	me, err := req.GetMe(cmd.Api, cmd.Token, out.Container.Id)
+	util.ExitOnErr(err)
+	out.TokenType = me.TokenType
+	util.ExitOutput(&out, cmd.Output, "")
+}
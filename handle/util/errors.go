@@ -0,0 +1,124 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/infrasonar"
+)
+
+// errorFormat is set to "json" to write errors as JSON.
+var errorFormat string
+
+// SetErrorFormat sets the format for errors, only "json" changes the output.
+func SetErrorFormat(output string) {
+	errorFormat = output
+}
+
+// InputError is an error in local input, for example an invalid input file
+// or a wrong combination of command-line arguments.
+type InputError struct {
+	Err error
+}
+
+func (e *InputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+func InputErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &InputError{Err: err}
+}
+
+var errorTypes = map[int]string{
+	ExitCodeErr:        "error",
+	ExitCodeAuth:       "auth",
+	ExitCodePermission: "permission",
+	ExitCodeNotFound:   "not_found",
+	ExitCodeValidation: "validation",
+	ExitCodeConflict:   "conflict",
+	ExitCodeTransient:  "transient",
+	ExitCodeInput:      "input",
+}
+
+// ExitCode returns the exit code for an error.
+func ExitCode(err error) int {
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		return ExitCodeInput
+	}
+
+	if errors.Is(err, cli.ErrLocked) || errors.Is(err, cli.ErrChanged) {
+		return ExitCodeConflict
+	}
+
+	var apiErr *infrasonar.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized:
+			return ExitCodeAuth
+		case http.StatusForbidden:
+			return ExitCodePermission
+		case http.StatusNotFound:
+			return ExitCodeNotFound
+		case http.StatusBadRequest, http.StatusUnprocessableEntity:
+			return ExitCodeValidation
+		case http.StatusConflict:
+			return ExitCodeConflict
+		}
+		if apiErr.Temporary() {
+			return ExitCodeTransient
+		}
+		return ExitCodeErr
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ExitCodeTransient
+	}
+	return ExitCodeErr
+}
+
+// printErr writes an error message to stderr, as JSON if required.
+func printErr(code int, msg string, err error) {
+	if errorFormat != "json" {
+		fmt.Fprintln(os.Stderr, msg)
+		return
+	}
+
+	type tError struct {
+		Type     string `json:"type"`
+		Code     int    `json:"code"`
+		Message  string `json:"message"`
+		Status   int    `json:"status,omitempty"`
+		Response string `json:"response,omitempty"`
+	}
+	out := struct {
+		Error tError `json:"error"`
+	}{
+		Error: tError{
+			Type:    errorTypes[code],
+			Code:    code,
+			Message: msg,
+		},
+	}
+	var apiErr *infrasonar.APIError
+	if errors.As(err, &apiErr) {
+		out.Error.Status = apiErr.StatusCode
+		out.Error.Response = apiErr.Message
+	}
+	data, _ := json.Marshal(out)
+	fmt.Fprintln(os.Stderr, string(data))
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/infrasonar"
+)
+
+func TestExitCode(t *testing.T) {
+	apiErr := func(status int) error {
+		return fmt.Errorf("failed to get asset (%w)", &infrasonar.APIError{StatusCode: status})
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"other error", errors.New("failed"), ExitCodeErr},
+		{"input error", InputErr(errors.New("invalid file")), ExitCodeInput},
+		{"wrapped input error", fmt.Errorf("apply: %w", InputErr(errors.New("invalid file"))), ExitCodeInput},
+		{"locked", fmt.Errorf("lock: %w", cli.ErrLocked), ExitCodeConflict},
+		{"changed", cli.ErrChanged, ExitCodeConflict},
+		{"401", apiErr(http.StatusUnauthorized), ExitCodeAuth},
+		{"403", apiErr(http.StatusForbidden), ExitCodePermission},
+		{"404", apiErr(http.StatusNotFound), ExitCodeNotFound},
+		{"400", apiErr(http.StatusBadRequest), ExitCodeValidation},
+		{"422", apiErr(http.StatusUnprocessableEntity), ExitCodeValidation},
+		{"409", apiErr(http.StatusConflict), ExitCodeConflict},
+		{"429", apiErr(http.StatusTooManyRequests), ExitCodeTransient},
+		{"500", apiErr(http.StatusInternalServerError), ExitCodeTransient},
+		{"503", apiErr(http.StatusServiceUnavailable), ExitCodeTransient},
+		{"418", apiErr(http.StatusTeapot), ExitCodeErr},
+		{"network error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ExitCodeTransient},
+		{"timeout", fmt.Errorf("request: %w", context.DeadlineExceeded), ExitCodeTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputErrNil(t *testing.T) {
+	if err := InputErr(nil); err != nil {
+		t.Errorf("InputErr(nil) = %v", err)
+	}
+}
+
+func TestExitCodeForClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/container/5":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "container not found"}`))
+		case "/container/6":
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+	client := infrasonar.NewClient(srv.URL, "token")
+
+	_, err := client.GetContainer(context.Background(), 5)
+	if got := ExitCode(err); got != ExitCodeNotFound {
+		t.Errorf("ExitCode = %d, want %d", got, ExitCodeNotFound)
+	}
+	if msg := infrasonar.ErrorMessage(err); msg != "container not found" {
+		t.Errorf("message = %q, want %q", msg, "container not found")
+	}
+
+	_, err = client.GetContainer(context.Background(), 6)
+	if got := ExitCode(err); got != ExitCodePermission {
+		t.Errorf("ExitCode = %d, want %d", got, ExitCodePermission)
+	}
+}
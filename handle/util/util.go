@@ -3,9 +3,11 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +20,21 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// Exit codes
+const (
+	ExitCodeOk         = 0  // Success, including "no changes"
+	ExitCodeErr        = 1  // Any error
+	ExitCodeCancelled  = 2  // Cancelled by answering "no"
+	ExitCodeNoTerminal = 3  // A question must be asked but stdin is not a terminal
+	ExitCodeAuth       = 4  // Invalid or expired token
+	ExitCodePermission = 5  // The token lacks a required permission
+	ExitCodeNotFound   = 6  // A container, asset, label etc. does not exist
+	ExitCodeValidation = 7  // Rejected by the API as invalid
+	ExitCodeConflict   = 8  // Conflicts with the current state
+	ExitCodeTransient  = 9  // Network error, timeout or unavailable API; try again later
+	ExitCodeInput      = 10 // Invalid input file or command-line arguments
+)
+
 type Simple interface {
 	Out() interface{}
 }
@@ -54,14 +71,49 @@ func Plural(n int) string {
 	return ""
 }
 
+func IsAre(n int) string {
+	if n != 1 {
+		return "are"
+	}
+	return "is"
+}
+
 func Color(format string, a ...any) {
 	fmt.Print(color.HiYellowString(format, a...))
 }
 
+func IsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Ask returns the given answer when not nil, otherwise the question is asked.
+func Ask(answer *bool, format string, a ...any) bool {
+	if answer != nil {
+		return *answer
+	}
+	Color(format, a...)
+	return AskForConfirmation()
+}
+
 func AskForConfirmation() bool {
+	if !IsTerminal() {
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Input is required but stdin is not a terminal. Use command-line flags (for example --yes) to run non-interactively.")
+		os.Exit(ExitCodeNoTerminal)
+	}
+
 	var response string
 	_, err := fmt.Scanln(&response)
 
+	if err == io.EOF {
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Input is required but stdin is closed.")
+		os.Exit(ExitCodeNoTerminal)
+	}
 	if err != nil {
 		response = ""
 	}
@@ -79,19 +131,40 @@ func AskForConfirmation() bool {
 	}
 }
 
+// ExitOnErr exits with an exit code for the type of error.
 func ExitOnErr(err error) {
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		code := ExitCode(err)
+		printErr(code, err.Error(), err)
+		os.Exit(code)
 	}
 }
 
 func ExitErr(format string, a ...any) {
-	if len(format) > 0 && format[len(format)-1] != '\n' {
-		format += "\n"
-	}
-	fmt.Fprintf(os.Stderr, format, a...)
-	os.Exit(1)
+	ExitErrCode(ExitCodeErr, format, a...)
+}
+
+// ExitInputErr exits with an error in the input file or command-line
+// arguments.
+func ExitInputErr(format string, a ...any) {
+	ExitErrCode(ExitCodeInput, format, a...)
+}
+
+// ExitErrFor exits with the given message and an exit code for err.
+func ExitErrFor(err error, format string, a ...any) {
+	code := ExitCode(err)
+	printErr(code, strings.TrimRight(fmt.Sprintf(format, a...), "\n"), err)
+	os.Exit(code)
+}
+
+func ExitErrCode(code int, format string, a ...any) {
+	printErr(code, strings.TrimRight(fmt.Sprintf(format, a...), "\n"), nil)
+	os.Exit(code)
+}
+
+func ExitCancelled() {
+	fmt.Println("Cancelled.")
+	os.Exit(ExitCodeCancelled)
 }
 
 func ExitOk(format string, a ...any) {
@@ -99,7 +172,7 @@ func ExitOk(format string, a ...any) {
 		format += "\n"
 	}
 	fmt.Printf(format, a...)
-	os.Exit(0)
+	os.Exit(ExitCodeOk)
 }
 
 func IsArray(v interface{}) bool {
@@ -177,26 +250,33 @@ func ExitOutput(out any, output string, outFn string) {
 	os.Exit(0)
 }
 
-func EnsureContainer(api, token string, containerId int) *cli.Container {
-	if containerId == 0 {
+// IsUserTokenErr returns true if a request failed because it requires a
+// container token.
+func IsUserTokenErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "This route only works with a container token")
+}
+
+// EnsureContainer returns the container for a container ID. Without
+// a container, the container of a container token is used.
+func EnsureContainer(api, token string, container string) *cli.Container {
+	var containerId int
+	if container == "" {
 		cid, err := req.GetContainerId(api, token)
 		if err != nil {
-			if strings.Contains(err.Error(), "This route only works with a container token") {
-				fmt.Fprintln(os.Stderr, "use the --container (-c) argument to specify a container or switch to a container token")
-			} else {
-				fmt.Fprintln(os.Stderr, err)
+			if IsUserTokenErr(err) {
+				ExitInputErr("use the --container (-c) argument to specify a container or switch to a container token")
 			}
-			os.Exit(1)
+			ExitOnErr(err)
 		}
 		containerId = cid
+	} else {
+		// The container is validated as an ID by the argument parser
+		containerId, _ = strconv.Atoi(container)
 	}
 
-	container, err := req.GetContainer(api, token, containerId)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-	return container
+	c, err := req.GetContainer(api, token, containerId)
+	ExitOnErr(err)
+	return c
 }
 
 func InSlice(haystack []string, needle string) *string {
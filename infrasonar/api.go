@@ -0,0 +1,48 @@
+package infrasonar
+
+import (
+	"context"
+)
+
+// API is implemented by Client. Use this interface to replace the client
+// in tests.
+type API interface {
+	GetAssetKinds(ctx context.Context) ([]string, error)
+	GetLabelColors(ctx context.Context) ([]string, error)
+
+	GetContainerId(ctx context.Context) (int, error)
+	GetContainer(ctx context.Context, containerId int) (*Container, error)
+	GetMe(ctx context.Context, containerId int) (*Me, error)
+
+	GetZones(ctx context.Context, containerId int) ([]*Zone, error)
+	UpsertZone(ctx context.Context, containerId, zone int, name string) error
+
+	GetCollectors(ctx context.Context, containerId int, fields []string, withOptions bool) ([]*Collector, error)
+	SetCollectorDisplay(ctx context.Context, containerId int, collectorKey string, display bool) error
+	VerifyCollectorConfig(ctx context.Context, collectorKey string, config map[string]any) error
+
+	GetAssets(ctx context.Context, containerId, assetId int, fields, filters []string, withCollectors bool) ([]*Asset, error)
+	CreateAsset(ctx context.Context, containerId int, name string) (int, error)
+	DeleteAsset(ctx context.Context, assetId int) error
+	SetAssetName(ctx context.Context, assetId int, name string) error
+	SetAssetKind(ctx context.Context, assetId int, kind string) error
+	SetAssetMode(ctx context.Context, assetId int, mode string, duration *int) error
+	SetAssetZone(ctx context.Context, assetId int, zoneId int) error
+	SetAssetDescription(ctx context.Context, assetId int, description string) error
+	AddLabelToAsset(ctx context.Context, assetId, labelId int) error
+	DeleteLabelFromAsset(ctx context.Context, assetId, labelId int) error
+	EnableAssetCheck(ctx context.Context, assetId int, collectorKey, checkKey string) error
+	DisableAssetCheck(ctx context.Context, assetId int, collectorKey, checkKey string) error
+	UpsertCollectorToAsset(ctx context.Context, assetId int, collectorKey string, config map[string]any) error
+	RemoveCollectorFromAsset(ctx context.Context, assetId int, collectorKey string) error
+
+	GetLabel(ctx context.Context, labelId int) (*Label, error)
+	GetLabels(ctx context.Context, labelIds []int) ([]*Label, error)
+	CreateLabel(ctx context.Context, containerId int, name string) (int, error)
+	DeleteLabel(ctx context.Context, labelId int) error
+	SetLabelName(ctx context.Context, labelId int, name string) error
+	SetLabelColor(ctx context.Context, labelId int, color string) error
+	SetLabelDescription(ctx context.Context, labelId int, description string) error
+}
+
+var _ API = (*Client)(nil)
@@ -0,0 +1,230 @@
+package infrasonar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/infrasonar/infrasonar-cli/re"
+)
+
+type Asset struct {
+	Id             int              `json:"id"`
+	ContainerId    int              `json:"container"`
+	Name           string           `json:"name"`
+	Zone           *int             `json:"zone"`
+	Labels         []int            `json:"labels"`
+	Description    string           `json:"description"`
+	Mode           string           `json:"mode"`
+	Kind           string           `json:"kind"`
+	Collectors     []AssetCollector `json:"collectors"`
+	DisabledChecks []DisabledCheck  `json:"disabledChecks"`
+	Properties     []AssetProperty  `json:"properties"`
+}
+
+type AssetCollector struct {
+	Key    string         `json:"key"`
+	Config map[string]any `json:"config,omitempty"`
+}
+
+type DisabledCheck struct {
+	Collector string `json:"collector"`
+	Check     string `json:"check"`
+}
+
+type AssetProperty struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// GetAssets returns the assets for a container, or a single asset when
+// assetId is not 0. Filters are in the form "field==value" or
+// "field!=value".
+func (c *Client) GetAssets(ctx context.Context, containerId, assetId int, fields, filters []string, withCollectors bool) ([]*Asset, error) {
+	if len(fields) == 0 {
+		fields = []string{"id"}
+	}
+	if assetId != 0 {
+		fields = append(fields, "container")
+	}
+	args := fmt.Sprintf("?fields=%s", strings.Join(fields, ","))
+	if withCollectors {
+		args += ",disabledChecks&collectors=key,config"
+	}
+	for _, filter := range filters {
+		m := re.AssetFilter.FindStringSubmatch(filter)
+		if m == nil {
+			continue
+		}
+		switch m[2] {
+		case "==", "=":
+			args += fmt.Sprintf("&%s=%s", m[1], m[3])
+		case "!=":
+			args += fmt.Sprintf("&not-%s=%s", m[1], m[3])
+		}
+	}
+	if assetId != 0 {
+		if len(filters) != 0 {
+			return nil, errors.New("cannot use both filters (-f/--filter) and asset ID (-a/--asset)")
+		}
+		var asset Asset
+		if err := c.get(ctx, c.url("/asset/%d%s", assetId, args), true, &asset); err != nil {
+			return nil, err
+		}
+		if asset.ContainerId != containerId {
+			return nil, fmt.Errorf("mismatch between container ID %d and asset ID %d", containerId, assetId)
+		}
+		// Just reset the container ID as it is no longer needed
+		asset.ContainerId = 0
+		return []*Asset{&asset}, nil
+	}
+
+	var assets []*Asset
+	if err := c.get(ctx, c.url("/container/%d/assets%s", containerId, args), true, &assets); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// CreateAsset creates an asset and returns the new asset ID.
+func (c *Client) CreateAsset(ctx context.Context, containerId int, name string) (int, error) {
+	data := struct {
+		Name string `json:"name"`
+	}{
+		Name: name,
+	}
+	body, err := c.do(ctx, "POST", c.url("/container/%d/asset", containerId), true, &data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to upsert asset '%s' (%w)", name, err)
+	}
+	var unpack struct {
+		AssetId int `json:"assetId"`
+	}
+	if err := json.Unmarshal(body, &unpack); err != nil {
+		return 0, err
+	}
+	if unpack.AssetId == 0 {
+		return 0, fmt.Errorf("unexpected asset ID 0 for asset '%s'", name)
+	}
+	return unpack.AssetId, nil
+}
+
+func (c *Client) DeleteAsset(ctx context.Context, assetId int) error {
+	if _, err := c.do(ctx, "DELETE", c.url("/asset/%d", assetId), true, nil); err != nil {
+		return fmt.Errorf("failed to delete asset ID %d (%w)", assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) SetAssetName(ctx context.Context, assetId int, name string) error {
+	data := struct {
+		Name string `json:"name"`
+	}{
+		Name: name,
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/asset/%d/name", assetId), true, &data); err != nil {
+		return fmt.Errorf("failed to set name '%s' for asset ID %d' (%w)", name, assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) SetAssetKind(ctx context.Context, assetId int, kind string) error {
+	data := struct {
+		Kind string `json:"kind"`
+	}{
+		Kind: kind,
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/asset/%d/kind", assetId), true, &data); err != nil {
+		return fmt.Errorf("failed to set kind '%s' for asset ID %d' (%w)", kind, assetId, err)
+	}
+	return nil
+}
+
+// SetAssetMode sets the mode of an asset. The duration in seconds is only
+// used for mode "maintenance"; nil means no end time.
+func (c *Client) SetAssetMode(ctx context.Context, assetId int, mode string, duration *int) error {
+	data := struct {
+		Mode     string `json:"mode"`
+		Duration *int   `json:"duration,omitempty"`
+	}{
+		Mode:     mode,
+		Duration: duration,
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/asset/%d/mode", assetId), true, &data); err != nil {
+		return fmt.Errorf("failed to set mode '%s' for asset ID %d' (%w)", mode, assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) SetAssetZone(ctx context.Context, assetId int, zoneId int) error {
+	data := struct {
+		Zone int `json:"zone"`
+	}{
+		Zone: zoneId,
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/asset/%d/zone", assetId), true, &data); err != nil {
+		return fmt.Errorf("failed to set zone ID %d for asset ID %d' (%w)", zoneId, assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) SetAssetDescription(ctx context.Context, assetId int, description string) error {
+	data := struct {
+		Description string `json:"description"`
+	}{
+		Description: description,
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/asset/%d/description", assetId), true, &data); err != nil {
+		return fmt.Errorf("failed to change the description for asset ID %d' (%w)", assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) AddLabelToAsset(ctx context.Context, assetId, labelId int) error {
+	if _, err := c.do(ctx, "PUT", c.url("/asset/%d/label/%d", assetId, labelId), true, nil); err != nil {
+		return fmt.Errorf("failed to add label ID %d to asset ID %d (%w)", labelId, assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) DeleteLabelFromAsset(ctx context.Context, assetId, labelId int) error {
+	if _, err := c.do(ctx, "DELETE", c.url("/asset/%d/label/%d", assetId, labelId), true, nil); err != nil {
+		return fmt.Errorf("failed to remove label ID %d from asset ID %d (%w)", labelId, assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) EnableAssetCheck(ctx context.Context, assetId int, collectorKey, checkKey string) error {
+	if _, err := c.do(ctx, "PUT", c.url("/asset/%d/collector/%s/check/%s", assetId, collectorKey, checkKey), true, nil); err != nil {
+		return fmt.Errorf("failed to enable check %s/%s on asset ID %d (%w)", collectorKey, checkKey, assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) DisableAssetCheck(ctx context.Context, assetId int, collectorKey, checkKey string) error {
+	if _, err := c.do(ctx, "DELETE", c.url("/asset/%d/collector/%s/check/%s", assetId, collectorKey, checkKey), true, nil); err != nil {
+		return fmt.Errorf("failed to disable check %s/%s on asset ID %d (%w)", collectorKey, checkKey, assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) UpsertCollectorToAsset(ctx context.Context, assetId int, collectorKey string, config map[string]any) error {
+	data := struct {
+		Config map[string]any `json:"config,omitempty"`
+	}{
+		Config: config,
+	}
+	if _, err := c.do(ctx, "POST", c.url("/asset/%d/collector/%s", assetId, collectorKey), true, &data); err != nil {
+		return fmt.Errorf("failed to upsert collector '%s' to asset ID %d (%w)", collectorKey, assetId, err)
+	}
+	return nil
+}
+
+func (c *Client) RemoveCollectorFromAsset(ctx context.Context, assetId int, collectorKey string) error {
+	if _, err := c.do(ctx, "DELETE", c.url("/asset/%d/collector/%s", assetId, collectorKey), true, nil); err != nil {
+		return fmt.Errorf("failed to remove collector '%s' from asset ID %d (%w)", collectorKey, assetId, err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Package infrasonar is a client for the InfraSonar API.
+package infrasonar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultBaseURL   = "https://api.infrasonar.com"
+	DefaultUserAgent = "InfraSonarGo"
+)
+
+// Client makes requests to the InfraSonar API. A Client is safe for
+// concurrent use.
+type Client struct {
+	BaseURL    string       // Default DefaultBaseURL
+	Token      string       // Container or user token
+	HTTPClient *http.Client // Default http.DefaultClient
+	UserAgent  string       // Default DefaultUserAgent
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		Token:   token,
+	}
+}
+
+// APIError is returned when the API responds with a status code other
+// than 2xx.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       string
+	Message    string // Error message from a JSON body, or the body itself
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s Response: %s", e.Status, e.Body)
+}
+
+// Temporary returns true when the request may succeed when it is repeated
+// later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode/100 == 5
+}
+
+func newAPIError(method, url string, resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		Method:     method,
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+		Message:    strings.TrimSpace(string(body)),
+	}
+	var unpack struct {
+		Error    string `json:"error"`
+		ErrorMsg string `json:"error_msg"`
+		Message  string `json:"message"`
+	}
+	if json.Unmarshal(body, &unpack) == nil {
+		for _, msg := range []string{unpack.Error, unpack.ErrorMsg, unpack.Message} {
+			if msg != "" {
+				e.Message = msg
+				break
+			}
+		}
+	}
+	return e
+}
+
+// ErrorMessage returns the message from the API if err is an *APIError, or
+// the error message otherwise.
+func ErrorMessage(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Message
+	}
+	return err.Error()
+}
+
+func (c *Client) url(format string, a ...any) string {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + fmt.Sprintf(format, a...)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+func (c *Client) userAgent() string {
+	if c.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return c.UserAgent
+}
+
+// do sends a request and returns the response body. The data, if not nil,
+// is sent as JSON. The token is only sent when auth is true.
+func (c *Client) do(ctx context.Context, method, url string, auth bool, data any) ([]byte, error) {
+	var body io.Reader
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent())
+	if auth {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return nil, newAPIError(method, url, resp, respBody)
+	}
+	return respBody, err
+}
+
+// get sends a GET request and unpacks the JSON response into v.
+func (c *Client) get(ctx context.Context, url string, auth bool, v any) error {
+	body, err := c.do(ctx, "GET", url, auth, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
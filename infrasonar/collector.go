@@ -0,0 +1,72 @@
+package infrasonar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type Collector struct {
+	Key  string `json:"key,omitempty" yaml:"key,omitempty"`
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Info string `json:"info,omitempty" yaml:"info,omitempty"`
+
+	Checks  []string `json:"checks" yaml:"checks"`
+	Options []struct {
+		Key     string `json:"key"`
+		Type    string `json:"type"`
+		Default any    `json:"default"`
+	} `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+func (c *Client) GetCollectors(ctx context.Context, containerId int, fields []string, withOptions bool) ([]*Collector, error) {
+	if len(fields) == 0 {
+		fields = []string{"key"}
+	}
+	if len(fields) == 1 && fields[0] != "key" {
+		fields = append(fields, "key")
+	}
+
+	args := fmt.Sprintf("?fields=%s", strings.Join(fields, ","))
+	if withOptions {
+		args += "&options=key,type,default"
+	}
+
+	var collectors []*Collector
+	if err := c.get(ctx, c.url("/container/%d/collectors%s", containerId, args), true, &collectors); err != nil {
+		return nil, err
+	}
+	return collectors, nil
+}
+
+func (c *Client) SetCollectorDisplay(ctx context.Context, containerId int, collectorKey string, display bool) error {
+	data := struct {
+		Display bool `json:"display"`
+	}{
+		Display: display,
+	}
+	x := func(display bool) string {
+		if display {
+			return "on"
+		}
+		return "off"
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/container/%d/collector/%s", containerId, collectorKey), true, &data); err != nil {
+		return fmt.Errorf("failed to set collector '%s' %s (%w)", collectorKey, x(display), err)
+	}
+	return nil
+}
+
+// VerifyCollectorConfig validates a collector configuration. When the
+// configuration is rejected, the error is an *APIError; the body of the
+// response explains why.
+func (c *Client) VerifyCollectorConfig(ctx context.Context, collectorKey string, config map[string]any) error {
+	data := struct {
+		Config any `json:"config"`
+	}{
+		Config: config,
+	}
+	_, err := c.do(ctx, "POST", c.url("/collector/%s", collectorKey), true, &data)
+	return err
+}
@@ -0,0 +1,102 @@
+package infrasonar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type Container struct {
+	Id   int    `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
+}
+
+func (c *Container) Str() string {
+	if c.Name == "" {
+		return fmt.Sprintf("%d", c.Id)
+	}
+	return c.Name
+}
+
+type Zone struct {
+	Zone int    `json:"zone" yaml:"zone"`
+	Name string `json:"name" yaml:"name"`
+}
+
+func (z *Zone) Str() string {
+	if z.Name == "" {
+		return fmt.Sprintf("%d", z.Zone)
+	}
+	return z.Name
+}
+
+// Me is the token information with the permissions for a container.
+type Me struct {
+	Permissions *[]string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+	TokenType   string    `json:"tokenType,omitempty" yaml:"tokenType,omitempty"`
+}
+
+func (c *Client) GetAssetKinds(ctx context.Context) ([]string, error) {
+	var assetKinds []string
+	err := c.get(ctx, c.url("/asset/kinds"), false, &assetKinds)
+	return assetKinds, err
+}
+
+func (c *Client) GetLabelColors(ctx context.Context) ([]string, error) {
+	var labelColors []string
+	err := c.get(ctx, c.url("/label/colors"), false, &labelColors)
+	return labelColors, err
+}
+
+// GetContainerId returns the container ID for a container token.
+func (c *Client) GetContainerId(ctx context.Context) (int, error) {
+	var unpack struct {
+		ContainerId int `json:"containerId"`
+	}
+	if err := c.get(ctx, c.url("/container/id"), true, &unpack); err != nil {
+		return 0, err
+	}
+	return unpack.ContainerId, nil
+}
+
+func (c *Client) GetContainer(ctx context.Context, containerId int) (*Container, error) {
+	var container Container
+	if err := c.get(ctx, c.url("/container/%d?fields=id,name", containerId), true, &container); err != nil {
+		return nil, err
+	}
+	if container.Id != containerId {
+		return nil, errors.New("container ID mismatch")
+	}
+	return &container, nil
+}
+
+// GetMe returns the token permissions for a container.
+func (c *Client) GetMe(ctx context.Context, containerId int) (*Me, error) {
+	var me Me
+	if err := c.get(ctx, c.url("/container/%d/permissions", containerId), true, &me); err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+func (c *Client) GetZones(ctx context.Context, containerId int) ([]*Zone, error) {
+	var zones []*Zone
+	if err := c.get(ctx, c.url("/container/%d/zones", containerId), true, &zones); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+func (c *Client) UpsertZone(ctx context.Context, containerId, zone int, name string) error {
+	data := struct {
+		Zone int    `json:"zone"`
+		Name string `json:"name"`
+	}{
+		Zone: zone,
+		Name: name,
+	}
+	if _, err := c.do(ctx, "POST", c.url("/container/%d/zone", containerId), true, &data); err != nil {
+		return fmt.Errorf("failed to upsert zone ID %d (%w)", zone, err)
+	}
+	return nil
+}
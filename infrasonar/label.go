@@ -0,0 +1,143 @@
+package infrasonar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+type Label struct {
+	Id          int    `json:"id" yaml:"id"`
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Color       string `json:"color,omitempty" yaml:"color,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+func (label *Label) Str() string {
+	if label.Name == "" {
+		return fmt.Sprintf("%d", label.Id)
+	}
+	return label.Name
+}
+
+// labelWorkers is the number of labels which are retrieved concurrently.
+const labelWorkers = 8
+
+func (c *Client) GetLabel(ctx context.Context, labelId int) (*Label, error) {
+	var label Label
+	if err := c.get(ctx, c.url("/label/%d?fields=id,name,color,description", labelId), true, &label); err != nil {
+		return nil, fmt.Errorf("failed to retrieve label ID %d (%w)", labelId, err)
+	}
+	return &label, nil
+}
+
+// GetLabels retrieves the given labels concurrently. The labels are returned
+// in order of label ID.
+func (c *Client) GetLabels(ctx context.Context, labelIds []int) ([]*Label, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	labels := make([]*Label, 0, len(labelIds))
+	queue := make(chan int)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	for w := 0; w < min(labelWorkers, len(labelIds)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for labelId := range queue {
+				label, err := c.GetLabel(ctx, labelId)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel() // No need to wait for the other labels
+				} else if err == nil {
+					labels = append(labels, label)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, labelId := range labelIds {
+		queue <- labelId
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	slices.SortFunc(labels, func(a, b *Label) int { return a.Id - b.Id })
+	return labels, nil
+}
+
+// CreateLabel creates a label and returns the new label ID.
+func (c *Client) CreateLabel(ctx context.Context, containerId int, name string) (int, error) {
+	data := struct {
+		Name string `json:"name"`
+	}{
+		Name: name,
+	}
+	body, err := c.do(ctx, "POST", c.url("/container/%d/label", containerId), true, &data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to upsert label '%s' (%w)", name, err)
+	}
+	var unpack struct {
+		LabelId int `json:"labelId"`
+	}
+	if err := json.Unmarshal(body, &unpack); err != nil {
+		return 0, err
+	}
+	if unpack.LabelId == 0 {
+		return 0, fmt.Errorf("unexpected label ID 0 for label '%s'", name)
+	}
+	return unpack.LabelId, nil
+}
+
+func (c *Client) DeleteLabel(ctx context.Context, labelId int) error {
+	if _, err := c.do(ctx, "DELETE", c.url("/label/%d", labelId), true, nil); err != nil {
+		return fmt.Errorf("failed to delete label ID %d (%w)", labelId, err)
+	}
+	return nil
+}
+
+func (c *Client) SetLabelName(ctx context.Context, labelId int, name string) error {
+	data := struct {
+		Name string `json:"name"`
+	}{
+		Name: name,
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/label/%d/name", labelId), true, &data); err != nil {
+		return fmt.Errorf("failed to set name '%s' for label ID %d' (%w)", name, labelId, err)
+	}
+	return nil
+}
+
+func (c *Client) SetLabelColor(ctx context.Context, labelId int, color string) error {
+	data := struct {
+		Color string `json:"color"`
+	}{
+		Color: color,
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/label/%d/color", labelId), true, &data); err != nil {
+		return fmt.Errorf("failed to set color '%s' for label ID %d' (%w)", color, labelId, err)
+	}
+	return nil
+}
+
+func (c *Client) SetLabelDescription(ctx context.Context, labelId int, description string) error {
+	data := struct {
+		Description string `json:"description"`
+	}{
+		Description: description,
+	}
+	if _, err := c.do(ctx, "PATCH", c.url("/label/%d/description", labelId), true, &data); err != nil {
+		return fmt.Errorf("failed to change the description for label ID %d' (%w)", labelId, err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package infrasonar
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRetries = 3
+	DefaultTimeout = 60 * time.Second
+
+	retryBaseWait = 500 * time.Millisecond
+	retryMaxWait  = 30 * time.Second
+	retryAfterMax = 120 * time.Second // Upper bound for a Retry-After header
+)
+
+// RetryTransport is an http.RoundTripper which retries failed requests with
+// exponential backoff and limits the request rate. Only idempotent requests
+// are retried, on connection errors and 429, 502, 503 and 504 responses.
+type RetryTransport struct {
+	Base      http.RoundTripper // Default http.DefaultTransport
+	Retries   int               // Number of retries after the first attempt
+	Timeout   time.Duration     // Timeout for a single attempt, 0 for no timeout
+	RateLimit float64           // Maximum requests per second, 0 for no limit
+
+	mu   sync.Mutex
+	next time.Time // Earliest time for the next request
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// wait blocks until the rate limit allows a new request.
+func (t *RetryTransport) wait(ctx context.Context) error {
+	if t.RateLimit <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	at := t.next
+	t.next = t.next.Add(time.Duration(float64(time.Second) / t.RateLimit))
+	t.mu.Unlock()
+
+	return sleep(ctx, time.Until(at))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// cancelBody cancels the context of an attempt when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (t *RetryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.Timeout <= 0 {
+		return t.base().RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout)
+	resp, err := t.base().RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errNoRetryBody
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := t.attempt(r)
+		if attempt >= t.Retries || ctx.Err() != nil || !shouldRetry(req.Method, resp, err) {
+			return resp, err
+		}
+
+		wait, ok := retryAfter(resp)
+		if !ok {
+			wait = backoff(attempt)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body) // Drain so the connection is reused
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+var errNoRetryBody = errors.New("cannot retry a request without GetBody")
+
+// isIdempotent returns true for methods which are safe to repeat.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		return isIdempotent(method)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+// retryAfter returns the wait time from a Retry-After header, if any.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = time.Until(at)
+	} else {
+		return 0, false
+	}
+	return min(max(wait, 0), retryAfterMax), true
+}
+
+// backoff returns an exponential wait time with jitter for the given attempt.
+func backoff(attempt int) time.Duration {
+	wait := retryMaxWait
+	if attempt < 16 {
+		wait = min(retryBaseWait<<attempt, retryMaxWait)
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
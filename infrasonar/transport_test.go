@@ -0,0 +1,165 @@
+package infrasonar
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	errConn := errors.New("connection refused")
+
+	tests := []struct {
+		name   string
+		method string
+		status int // 0 for a connection error
+		want   bool
+	}{
+		{"connection error on GET", "GET", 0, true},
+		{"connection error on DELETE", "DELETE", 0, true},
+		{"connection error on POST", "POST", 0, false},
+		{"connection error on PATCH", "PATCH", 0, false},
+		{"429 on GET", "GET", http.StatusTooManyRequests, true},
+		{"429 on POST", "POST", http.StatusTooManyRequests, false},
+		{"502 on GET", "GET", http.StatusBadGateway, true},
+		{"503 on PUT", "PUT", http.StatusServiceUnavailable, true},
+		{"504 on HEAD", "HEAD", http.StatusGatewayTimeout, true},
+		{"503 on POST", "POST", http.StatusServiceUnavailable, false},
+		{"500 on GET", "GET", http.StatusInternalServerError, false},
+		{"404 on GET", "GET", http.StatusNotFound, false},
+		{"200 on GET", "GET", http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			var err error
+			if tt.status == 0 {
+				err = errConn
+			} else {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			if got := shouldRetry(tt.method, resp, err); got != tt.want {
+				t.Errorf("shouldRetry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTripRetries(t *testing.T) {
+	tests := []struct {
+		method   string
+		body     string
+		attempts int
+		status   int
+	}{
+		{"GET", "", 3, http.StatusOK},
+		{"DELETE", "", 3, http.StatusOK},
+		{"PUT", `{"name": "web"}`, 3, http.StatusOK},
+		{"POST", `{"name": "web"}`, 1, http.StatusTooManyRequests},
+		{"PATCH", `{"name": "web"}`, 1, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if body, _ := io.ReadAll(r.Body); string(body) != tt.body {
+					t.Errorf("attempt %d: body = %q, want %q", attempts, body, tt.body)
+				}
+				if attempts < 3 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				}
+			}))
+			defer srv.Close()
+
+			req, err := http.NewRequest(tt.method, srv.URL, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &http.Client{Transport: &RetryTransport{Retries: 3}}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status || attempts != tt.attempts {
+				t.Errorf("status = %d after %d attempts, want %d after %d", resp.StatusCode, attempts, tt.status, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"no header", "", 0, false},
+		{"seconds", "5", 5 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"negative", "-5", 0, true},
+		{"above maximum", "3600", retryAfterMax, true},
+		{"date in the past", "Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+		{"invalid", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.value != "" {
+				resp.Header.Set("Retry-After", tt.value)
+			}
+			got, ok := retryAfter(resp)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("retryAfter = %s, %v, want %s, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(10*time.Second).UTC().Format(http.TimeFormat))
+	got, ok := retryAfter(resp)
+	if !ok || got <= 8*time.Second || got > 10*time.Second {
+		t.Errorf("retryAfter = %s, %v, want about 10s", got, ok)
+	}
+}
+
+func TestRetryAfterWithoutResponse(t *testing.T) {
+	if got, ok := retryAfter(nil); got != 0 || ok {
+		t.Errorf("retryAfter = %s, %v, want 0, false", got, ok)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, retryBaseWait},
+		{1, 2 * retryBaseWait},
+		{3, 8 * retryBaseWait},
+		{6, retryMaxWait},
+		{16, retryMaxWait},
+		{100, retryMaxWait},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			got := backoff(tt.attempt)
+			if got < tt.max/2 || got > tt.max {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
@@ -10,7 +10,7 @@ _infrasonar_completions()
 
     if [[ "${COMP_WORDS[1]}" == "config" ]]; then
         if [[ "$COMP_CWORD" == "2" ]]; then
-            local COMPLETES="list new update default delete"
+            local COMPLETES="list new update default delete rekey test"
             COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
             return 0
         fi
@@ -25,7 +25,7 @@ _infrasonar_completions()
 
         if [[ "${COMP_WORDS[2]}" == "new" ]]; then
             if [[ "$cur" == --* ]]; then
-                local COMPLETES="--set-name --set-token --set-api --set-output --set-default --help"
+                local COMPLETES="--set-name --set-token --set-api --set-output --set-default --set-retries --set-timeout --set-container --set-cache-ttl --set-rate-limit --set-proxy --set-ca-bundle --set-client-cert --set-client-key --set-insecure-skip-verify --help"
                 COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
                 return 0
             fi
@@ -33,7 +33,7 @@ _infrasonar_completions()
 
         if [[ "${COMP_WORDS[2]}" == "update" ]]; then
             if [[ "$cur" == --* ]]; then
-                local COMPLETES="--config --set-token --set-api --set-output --set-default --help"
+                local COMPLETES="--config --set-token --set-api --set-output --set-default --set-retries --set-timeout --set-container --set-cache-ttl --set-rate-limit --set-proxy --set-ca-bundle --set-client-cert --set-client-key --set-insecure-skip-verify --help"
                 COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
                 return 0
             fi
@@ -54,6 +54,31 @@ _infrasonar_completions()
                 return 0
             fi
         fi
+
+        if [[ "${COMP_WORDS[2]}" == "test" ]]; then
+            if [[ "$cur" == --* ]]; then
+                local COMPLETES="--config --container --help"
+                COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
+                return 0
+            fi
+        fi
+
+        if [[ "${COMP_WORDS[2]}" == "rekey" ]]; then
+            if [[ "$prev" == "--method" || "$prev" == "-m" ]]; then
+                local COMPLETES="passphrase key-file builtin"
+                COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
+                return 0
+            fi
+            if [[ "$prev" == "--key-file" ]]; then
+                COMPREPLY=( $(compgen -f -- ${COMP_WORDS[COMP_CWORD]}) )
+                return 0
+            fi
+            if [[ "$cur" == --* ]]; then
+                local COMPLETES="--method --key-file --help"
+                COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
+                return 0
+            fi
+        fi
         return 0
     fi
 
@@ -118,7 +143,7 @@ _infrasonar_completions()
             fi
 
             if [[ "$cur" == --* ]]; then
-                local COMPLETES="--container --asset --properties --filter --include-defaults --output --target-filename --use-config --help"
+                local COMPLETES="--container --asset --properties --filter --include-defaults --output --target-filename --use-config --token-file --verbose --debug --trace-file --help"
                 COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
                 return 0
             fi
@@ -139,7 +164,7 @@ _infrasonar_completions()
             fi
 
             if [[ "$cur" == --* ]]; then
-                local COMPLETES="--container --collector --properties --output --target-filename --use-config --help"
+                local COMPLETES="--container --collector --properties --output --target-filename --use-config --token-file --verbose --debug --trace-file --help"
                 COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
                 return 0
             fi
@@ -156,7 +181,7 @@ _infrasonar_completions()
             fi
 
             if [[ "$cur" == --* ]]; then
-                local COMPLETES="--container --properties --output --target-filename --use-config --help"
+                local COMPLETES="--container --properties --output --target-filename --use-config --token-file --verbose --debug --trace-file --help"
                 COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
                 return 0
             fi
@@ -165,7 +190,7 @@ _infrasonar_completions()
 
         if [[ "${COMP_WORDS[2]}" == "all-asset-kinds" ]] || [[ "${COMP_WORDS[2]}" == "all-label-colors" ]]; then
             if [[ "$cur" == --* ]]; then
-                local COMPLETES="--output --target-filename --use-config --help"
+                local COMPLETES="--output --target-filename --use-config --token-file --verbose --debug --trace-file --help"
                 COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
                 return 0
             fi
@@ -177,7 +202,19 @@ _infrasonar_completions()
 
     if [[ "${COMP_WORDS[1]}" == "apply" ]]; then
 
-        if [[ "$prev" == "-f" ]] || [[ "$prev" == "--filename" ]]; then
+        if [[ "$prev" == "--plan-format" ]]; then
+            local COMPLETES="json yaml"
+            COMPREPLY=( $(compgen -W "$COMPLETES" -- ${cur}) )
+            return 0
+        fi
+
+        if [[ "$prev" == "--purge-scope" ]]; then
+            local COMPLETES="labels"
+            COMPREPLY=( $(compgen -W "$COMPLETES" -- ${cur}) )
+            return 0
+        fi
+
+        if [[ "$prev" == "-f" ]] || [[ "$prev" == "--filename" ]] || [[ "$prev" == "--plan-in" ]]; then
             local FILEPATH COMPLETES
             FILEPATH="$(dirname "${cur}")";
 
@@ -203,14 +240,67 @@ _infrasonar_completions()
         fi
 
         if [[ "$cur" == --* ]]; then
-            local COMPLETES="--filename --dry-run --purge --use-config --help"
+            local COMPLETES="--filename --dry-run --purge --purge-assets --purge-scope --use-config --yes --confirm-destructive --use-cache --no-cache --remote-validate --no-remote-validate --show-details --plan-format --plan-out --plan-in --atomic --resume --parallel --match-by --write-back --token-file --verbose --debug --trace-file --help"
+            COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
+            return 0
+        fi
+        return 0
+    fi
+
+    if [[ "${COMP_WORDS[1]}" == "cache" ]]; then
+        if [[ "$COMP_CWORD" == "2" ]]; then
+            local COMPLETES="list show clear"
+            COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
+            return 0
+        fi
+
+        if [[ "${COMP_WORDS[2]}" == "list" ]]; then
+            if [[ "$cur" == --* ]]; then
+                local COMPLETES="--help"
+                COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
+                return 0
+            fi
+        fi
+
+        if [[ "${COMP_WORDS[2]}" == "show" ]]; then
+            if [[ "$prev" == "-o" ]] || [[ "$prev" == "--output" ]]; then
+                local COMPLETES="json yaml"
+                COMPREPLY=( $(compgen -W "$COMPLETES" -- ${cur}) )
+                return 0
+            fi
+            if [[ "$cur" == --* ]]; then
+                local COMPLETES="--container --output --use-config --help"
+                COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
+                return 0
+            fi
+        fi
+
+        if [[ "${COMP_WORDS[2]}" == "clear" ]]; then
+            if [[ "$cur" == --* ]]; then
+                local COMPLETES="--container --use-config --help"
+                COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
+                return 0
+            fi
+        fi
+        return 0
+    fi
+
+    if [[ "${COMP_WORDS[1]}" == "whoami" ]]; then
+        if [[ "$prev" == "-o" ]] || [[ "$prev" == "--output" ]]; then
+            local COMPLETES="json yaml"
+            COMPREPLY=( $(compgen -W "$COMPLETES" -- ${cur}) )
+            return 0
+        fi
+
+        if [[ "$cur" == --* ]]; then
+            local COMPLETES="--output --use-config --token-file --container --verbose --debug --trace-file --help"
             COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
             return 0
         fi
         return 0
     fi
 
-    local COMPLETES="version install config get apply"
+    local COMPLETES="version install config get apply cache whoami"
     COMPREPLY=( $(compgen -W "$COMPLETES" -- ${COMP_WORDS[COMP_CWORD]}) )
     return 0
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/akamensky/argparse"
@@ -12,6 +13,7 @@ import (
 	"github.com/infrasonar/infrasonar-cli/handle/util"
 	"github.com/infrasonar/infrasonar-cli/install"
 	"github.com/infrasonar/infrasonar-cli/options"
+	"github.com/infrasonar/infrasonar-cli/req"
 )
 
 func getOutput(outputArg string, config *conf.Config) string {
@@ -21,6 +23,15 @@ func getOutput(outputArg string, config *conf.Config) string {
 	return outputArg
 }
 
+// errorFormat returns the format for errors which occur before the output of
+// the configuration is resolved.
+func errorFormat(outputArg string) string {
+	if outputArg == "" {
+		return os.Getenv(conf.EnvOutput)
+	}
+	return outputArg
+}
+
 func testTargetFilename(fn string) error {
 	if fn != "" {
 		fp, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY, 0644)
@@ -32,6 +43,42 @@ func testTargetFilename(fn string) error {
 	return nil
 }
 
+func getAnswer(yes, no bool, yesFlag, noFlag string) *bool {
+	if yes && no {
+		util.ExitInputErr("cannot use both --%s and --%s", yesFlag, noFlag)
+	}
+	if yes || no {
+		return &yes
+	}
+	return nil
+}
+
+// getParsed returns the value of an argument, or nil if the argument is not
+// given on the command line.
+func getParsed[T any](cmd *argparse.Command, lname string, value *T) *T {
+	for _, arg := range cmd.GetArgs() {
+		if arg.GetLname() == lname && arg.GetParsed() {
+			return value
+		}
+	}
+	return nil
+}
+
+func getBool(s *string) *bool {
+	if s == nil {
+		return nil
+	}
+	b := *s == "true"
+	return &b
+}
+
+func configureRequests(config *conf.Config) {
+	util.ExitOnErr(util.InputErr(req.Configure(config.RequestSettings())))
+	if config.InsecureSkipVerify {
+		fmt.Fprintf(os.Stderr, "Warning: TLS certificate verification is disabled for configuration '%s'\n", config.Name)
+	}
+}
+
 func getAssetProperties(properties string) []string {
 	if properties == "" {
 		return cli.AssetProperties
@@ -55,6 +102,9 @@ func getMeProperties(properties string) []string {
 
 func main() {
 	parser := argparse.NewParser("infrasonar", "InfraSonar Client")
+	verbose := parser.Flag("v", "verbose", options.Verbose)
+	debug := parser.Flag("", "debug", options.Debug)
+	traceFile := parser.String("", "trace-file", options.TraceFile)
 
 	// CMD: version
 	cmdVersion := parser.NewCommand("version", "Print version and exit")
@@ -76,6 +126,16 @@ func main() {
 	cmdConfigNewSetApi := cmdConfigNew.String("", "set-api", options.ConfigNewApi)
 	cmdConfigNewSetOutput := cmdConfigNew.String("", "set-output", options.DefaultOutput)
 	cmdConfigNewSetDefault := cmdConfigNew.Flag("", "set-default", options.ConfigSetDefault)
+	cmdConfigNewSetRetries := cmdConfigNew.Int("", "set-retries", options.ConfigRetries)
+	cmdConfigNewSetTimeout := cmdConfigNew.Int("", "set-timeout", options.ConfigTimeout)
+	cmdConfigNewSetContainer := cmdConfigNew.Int("", "set-container", options.ConfigContainer)
+	cmdConfigNewSetCacheTtl := cmdConfigNew.Int("", "set-cache-ttl", options.ConfigCacheTtl)
+	cmdConfigNewSetRateLimit := cmdConfigNew.Float("", "set-rate-limit", options.ConfigRateLimit)
+	cmdConfigNewSetProxy := cmdConfigNew.String("", "set-proxy", options.ConfigProxy)
+	cmdConfigNewSetCaBundle := cmdConfigNew.String("", "set-ca-bundle", options.ConfigCaBundle)
+	cmdConfigNewSetClientCert := cmdConfigNew.String("", "set-client-cert", options.ConfigClientCert)
+	cmdConfigNewSetClientKey := cmdConfigNew.String("", "set-client-key", options.ConfigClientKey)
+	cmdConfigNewSetInsecureSkipVerify := cmdConfigNew.Selector("", "set-insecure-skip-verify", []string{"true", "false"}, options.ConfigInsecureSkipVerify)
 
 	// CMD: config update
 	cmdConfigUpdate := cmdConfig.NewCommand("update", "Update a client configuration")
@@ -84,6 +144,16 @@ func main() {
 	cmdConfigUpdateSetApi := cmdConfigUpdate.String("", "set-api", options.ConfigUpdateApi)
 	cmdConfigUpdateSetOutput := cmdConfigUpdate.String("", "set-output", options.Output)
 	cmdConfigUpdateSetDefault := cmdConfigUpdate.Flag("", "set-default", options.ConfigSetDefault)
+	cmdConfigUpdateSetRetries := cmdConfigUpdate.Int("", "set-retries", options.ConfigRetries)
+	cmdConfigUpdateSetTimeout := cmdConfigUpdate.Int("", "set-timeout", options.ConfigTimeout)
+	cmdConfigUpdateSetContainer := cmdConfigUpdate.Int("", "set-container", options.ConfigContainer)
+	cmdConfigUpdateSetCacheTtl := cmdConfigUpdate.Int("", "set-cache-ttl", options.ConfigCacheTtl)
+	cmdConfigUpdateSetRateLimit := cmdConfigUpdate.Float("", "set-rate-limit", options.ConfigRateLimit)
+	cmdConfigUpdateSetProxy := cmdConfigUpdate.String("", "set-proxy", options.ConfigProxy)
+	cmdConfigUpdateSetCaBundle := cmdConfigUpdate.String("", "set-ca-bundle", options.ConfigCaBundle)
+	cmdConfigUpdateSetClientCert := cmdConfigUpdate.String("", "set-client-cert", options.ConfigClientCert)
+	cmdConfigUpdateSetClientKey := cmdConfigUpdate.String("", "set-client-key", options.ConfigClientKey)
+	cmdConfigUpdateSetInsecureSkipVerify := cmdConfigUpdate.Selector("", "set-insecure-skip-verify", []string{"true", "false"}, options.ConfigInsecureSkipVerify)
 
 	// CMD: config default
 	cmdConfigDefault := cmdConfig.NewCommand("default", "Show the default client configuration")
@@ -92,15 +162,50 @@ func main() {
 	cmdConfigDelete := cmdConfig.NewCommand("delete", "Delete a client configuration")
 	cmdConfigDeleteName := cmdConfigDelete.String("c", "config", options.ConfigName)
 
+	// CMD: config rekey
+	cmdConfigRekey := cmdConfig.NewCommand("rekey", "Encrypt all tokens with a new key")
+	cmdConfigRekeyMethod := cmdConfigRekey.Selector("m", "method", []string{conf.KeyPassphrase, conf.KeyFile, conf.KeyBuiltin}, options.ConfigRekeyMethod)
+	cmdConfigRekeyKeyFile := cmdConfigRekey.String("", "key-file", options.ConfigRekeyKeyFile)
+
+	// CMD: config test
+	cmdConfigTest := cmdConfig.NewCommand("test", "Test a client configuration")
+	cmdConfigTestName := cmdConfigTest.String("c", "config", options.ConfigName)
+	cmdConfigTestContainer := cmdConfigTest.String("", "container", options.ConfigTestContainer)
+
+	// CMD: whoami
+	cmdWhoami := parser.NewCommand("whoami", "Show the configuration, API, container and token type in use")
+	cmdWhoamiOutput := cmdWhoami.String("o", "output", options.WhoamiOutput)
+	cmdWhoamiUseConfig := cmdWhoami.String("u", "use-config", options.UseConfig)
+	cmdWhoamiTokenFile := cmdWhoami.String("", "token-file", options.TokenFile)
+	cmdWhoamiContainer := cmdWhoami.String("c", "container", options.Container)
+
+	// CMD: cache
+	cmdCache := parser.NewCommand("cache", "Manage cached container states")
+
+	// CMD: cache list
+	cmdCacheList := cmdCache.NewCommand("list", "List cached container states")
+
+	// CMD: cache show
+	cmdCacheShow := cmdCache.NewCommand("show", "Show a cached container state")
+	cmdCacheShowContainer := cmdCacheShow.Int("c", "container", options.CacheShowContainer)
+	cmdCacheShowOutput := cmdCacheShow.String("o", "output", options.Output)
+	cmdCacheShowUseConfig := cmdCacheShow.String("u", "use-config", options.UseConfig)
+
+	// CMD: cache clear
+	cmdCacheClear := cmdCache.NewCommand("clear", "Remove cached container states")
+	cmdCacheClearContainer := cmdCacheClear.Int("c", "container", options.CacheContainer)
+	cmdCacheClearUseConfig := cmdCacheClear.String("u", "use-config", options.UseConfig)
+
 	// CMD: get
 	cmdGet := parser.NewCommand("get", "Read from InfraSonar")
 	cmdGetOutput := cmdGet.String("o", "output", options.Output)
 	cmdGetTargetFilename := cmdGet.String("t", "target-filename", options.OutFileName)
 	cmdGetUseConfig := cmdGet.String("u", "use-config", options.UseConfig)
+	cmdGetTokenFile := cmdGet.String("", "token-file", options.TokenFile)
 
 	// CMD: get assets
 	cmdGetAssets := cmdGet.NewCommand("assets", "Get container assets")
-	cmdGetAssetsContainer := cmdGetAssets.Int("c", "container", options.Container)
+	cmdGetAssetsContainer := cmdGetAssets.String("c", "container", options.Container)
 	cmdGetAssetsAsset := cmdGetAssets.Int("a", "asset", options.Asset)
 	cmdGetAssetsProperties := cmdGetAssets.String("p", "properties", options.AssetProperties)
 	cmdGetAssetsFilter := cmdGetAssets.StringList("f", "filter", options.AssetFilter)
@@ -108,13 +213,13 @@ func main() {
 
 	// CMD: get collectors
 	cmdGetCollectors := cmdGet.NewCommand("collectors", "Get container collectors")
-	cmdGetCollectorsContainer := cmdGetCollectors.Int("c", "container", options.Container)
+	cmdGetCollectorsContainer := cmdGetCollectors.String("c", "container", options.Container)
 	cmdGetCollectorsProperties := cmdGetCollectors.String("p", "properties", options.CollectorProperties)
 	cmdGetCollectorsCollector := cmdGetCollectors.String("k", "collector", options.Collector)
 
 	// CMD: get collectors
 	cmdGetMe := cmdGet.NewCommand("me", "Get token information (permissions and/or token type)")
-	cmdGetMeContainer := cmdGetMe.Int("c", "container", options.Container)
+	cmdGetMeContainer := cmdGetMe.String("c", "container", options.Container)
 	cmdGetMeProperties := cmdGetMe.String("p", "properties", options.MeProperties)
 
 	// CMD: get all-asset-kinds
@@ -128,7 +233,25 @@ func main() {
 	cmdApplyFileName := cmdApply.String("f", "filename", options.ApplyFileName)
 	cmdApplyDryRun := cmdApply.Flag("d", "dry-run", options.DryRun)
 	cmdApplyPurge := cmdApply.Flag("p", "purge", options.Purge)
+	cmdApplyPurgeAssets := cmdApply.Flag("", "purge-assets", options.PurgeAssets)
+	cmdApplyPurgeScope := cmdApply.String("", "purge-scope", options.PurgeScope)
 	cmdApplyUseConfig := cmdApply.String("u", "use-config", options.UseConfig)
+	cmdApplyTokenFile := cmdApply.String("", "token-file", options.TokenFile)
+	cmdApplyYes := cmdApply.Flag("y", "yes", options.Yes)
+	cmdApplyConfirmDestructive := cmdApply.Flag("", "confirm-destructive", options.ConfirmDestructive)
+	cmdApplyUseCache := cmdApply.Flag("", "use-cache", options.UseCache)
+	cmdApplyNoCache := cmdApply.Flag("", "no-cache", options.NoCache)
+	cmdApplyRemoteValidate := cmdApply.Flag("", "remote-validate", options.RemoteValidate)
+	cmdApplyNoRemoteValidate := cmdApply.Flag("", "no-remote-validate", options.NoRemoteValidate)
+	cmdApplyShowDetails := cmdApply.Flag("", "show-details", options.ShowDetails)
+	cmdApplyPlanFormat := cmdApply.String("", "plan-format", options.PlanFormat)
+	cmdApplyPlanOut := cmdApply.String("", "plan-out", options.PlanOut)
+	cmdApplyPlanIn := cmdApply.String("", "plan-in", options.PlanIn)
+	cmdApplyAtomic := cmdApply.Flag("", "atomic", options.Atomic)
+	cmdApplyResume := cmdApply.Flag("", "resume", options.Resume)
+	cmdApplyParallel := cmdApply.Int("", "parallel", options.Parallel)
+	cmdApplyMatchBy := cmdApply.String("", "match-by", options.MatchBy)
+	cmdApplyWriteBack := cmdApply.Flag("", "write-back", options.WriteBack)
 
 	// Parse input
 	err := parser.Parse(os.Args)
@@ -138,6 +261,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	traceLevel := req.TraceOff
+	if *debug {
+		traceLevel = req.TraceDebug
+	} else if *verbose {
+		traceLevel = req.TraceVerbose
+	}
+	util.ExitOnErr(req.Trace(traceLevel, *traceFile))
+
 	// CMD: version
 	if cmdVersion.Happened() {
 		handle.Version()
@@ -168,6 +299,17 @@ func main() {
 				Api:        *cmdConfigNewSetApi,
 				Output:     *cmdConfigNewSetOutput,
 				SetDefault: *cmdConfigNewSetDefault,
+				Retries:    getParsed(cmdConfigNew, "set-retries", cmdConfigNewSetRetries),
+				Timeout:    getParsed(cmdConfigNew, "set-timeout", cmdConfigNewSetTimeout),
+				Container:  getParsed(cmdConfigNew, "set-container", cmdConfigNewSetContainer),
+				CacheTtl:   getParsed(cmdConfigNew, "set-cache-ttl", cmdConfigNewSetCacheTtl),
+				RateLimit:  getParsed(cmdConfigNew, "set-rate-limit", cmdConfigNewSetRateLimit),
+
+				Proxy:              getParsed(cmdConfigNew, "set-proxy", cmdConfigNewSetProxy),
+				CaBundle:           getParsed(cmdConfigNew, "set-ca-bundle", cmdConfigNewSetCaBundle),
+				ClientCert:         getParsed(cmdConfigNew, "set-client-cert", cmdConfigNewSetClientCert),
+				ClientKey:          getParsed(cmdConfigNew, "set-client-key", cmdConfigNewSetClientKey),
+				InsecureSkipVerify: getBool(getParsed(cmdConfigNew, "set-insecure-skip-verify", cmdConfigNewSetInsecureSkipVerify)),
 			})
 		}
 
@@ -179,6 +321,17 @@ func main() {
 				Api:        *cmdConfigUpdateSetApi,
 				Output:     *cmdConfigUpdateSetOutput,
 				SetDefault: *cmdConfigUpdateSetDefault,
+				Retries:    getParsed(cmdConfigUpdate, "set-retries", cmdConfigUpdateSetRetries),
+				Timeout:    getParsed(cmdConfigUpdate, "set-timeout", cmdConfigUpdateSetTimeout),
+				Container:  getParsed(cmdConfigUpdate, "set-container", cmdConfigUpdateSetContainer),
+				CacheTtl:   getParsed(cmdConfigUpdate, "set-cache-ttl", cmdConfigUpdateSetCacheTtl),
+				RateLimit:  getParsed(cmdConfigUpdate, "set-rate-limit", cmdConfigUpdateSetRateLimit),
+
+				Proxy:              getParsed(cmdConfigUpdate, "set-proxy", cmdConfigUpdateSetProxy),
+				CaBundle:           getParsed(cmdConfigUpdate, "set-ca-bundle", cmdConfigUpdateSetCaBundle),
+				ClientCert:         getParsed(cmdConfigUpdate, "set-client-cert", cmdConfigUpdateSetClientCert),
+				ClientKey:          getParsed(cmdConfigUpdate, "set-client-key", cmdConfigUpdateSetClientKey),
+				InsecureSkipVerify: getBool(getParsed(cmdConfigUpdate, "set-insecure-skip-verify", cmdConfigUpdateSetInsecureSkipVerify)),
 			})
 		}
 
@@ -191,11 +344,75 @@ func main() {
 		if cmdConfigDelete.Happened() {
 			handle.ConfigDelete(*cmdConfigDeleteName)
 		}
+
+		// CMD: config rekey
+		if cmdConfigRekey.Happened() {
+			handle.ConfigRekey(*cmdConfigRekeyMethod, *cmdConfigRekeyKeyFile)
+		}
+
+		// CMD: config test
+		if cmdConfigTest.Happened() {
+			config := conf.Resolve(*cmdConfigTestName, "")
+			configureRequests(config)
+			handle.ConfigTest(&handle.TConfigTest{
+				Name:      config.Name,
+				Api:       config.Api,
+				Token:     config.EnsureToken(),
+				Container: config.ContainerOrDefault(*cmdConfigTestContainer),
+			})
+		}
+	}
+
+	// CMD: cache
+	if cmdCache.Happened() {
+		// CMD: cache list
+		if cmdCacheList.Happened() {
+			handle.CacheList()
+		}
+
+		// CMD: cache show
+		if cmdCacheShow.Happened() {
+			util.SetErrorFormat(errorFormat(*cmdCacheShowOutput))
+			config := conf.Resolve(*cmdCacheShowUseConfig, "")
+			output := getOutput(*cmdCacheShowOutput, config)
+			util.SetErrorFormat(output)
+			handle.CacheShow(config.Api, *cmdCacheShowContainer, output)
+		}
+
+		// CMD: cache clear
+		if cmdCacheClear.Happened() {
+			api := ""
+			if *cmdCacheClearContainer != 0 {
+				api = conf.Resolve(*cmdCacheClearUseConfig, "").Api
+			}
+			handle.CacheClear(api, *cmdCacheClearContainer)
+		}
+	}
+
+	// CMD: whoami
+	if cmdWhoami.Happened() {
+		util.SetErrorFormat(errorFormat(*cmdWhoamiOutput))
+		config := conf.Resolve(*cmdWhoamiUseConfig, *cmdWhoamiTokenFile)
+		configureRequests(config)
+		output := *cmdWhoamiOutput
+		if output == "" {
+			output = "simple"
+		}
+		util.SetErrorFormat(output)
+		handle.Whoami(&handle.TWhoami{
+			Config:    config.Name,
+			Api:       config.Api,
+			Token:     config.EnsureToken(),
+			Output:    output,
+			Container: config.ContainerOrDefault(*cmdWhoamiContainer),
+		})
 	}
 
 	// CMD: get
 	if cmdGet.Happened() {
-		config := conf.EnsureConfig(*cmdGetUseConfig)
+		util.SetErrorFormat(errorFormat(*cmdGetOutput))
+		config := conf.Resolve(*cmdGetUseConfig, *cmdGetTokenFile)
+		configureRequests(config)
 		outFn := *cmdGetTargetFilename
 		output := *cmdGetOutput
 		if outFn != "" {
@@ -204,12 +421,13 @@ func main() {
 				if output == "" || o == output {
 					output = o
 				} else if output != "" {
-					util.ExitErr("output type does not match output file")
+					util.ExitInputErr("output type does not match output file")
 				}
 			}
 		} else {
 			output = getOutput(output, config)
 		}
+		util.SetErrorFormat(output)
 
 		util.ExitOnErr(testTargetFilename(outFn))
 
@@ -220,7 +438,7 @@ func main() {
 				Token:           config.EnsureToken(),
 				Output:          output,
 				OutFn:           outFn,
-				Container:       *cmdGetAssetsContainer,
+				Container:       config.ContainerOrDefault(*cmdGetAssetsContainer),
 				Asset:           *cmdGetAssetsAsset,
 				Properties:      getAssetProperties(*cmdGetAssetsProperties),
 				Filters:         *cmdGetAssetsFilter,
@@ -235,7 +453,7 @@ func main() {
 				Token:      config.EnsureToken(),
 				Output:     output,
 				OutFn:      outFn,
-				Container:  *cmdGetCollectorsContainer,
+				Container:  config.ContainerOrDefault(*cmdGetCollectorsContainer),
 				Properties: getCollectorProperties(*cmdGetCollectorsProperties),
 				Collector:  *cmdGetCollectorsCollector,
 			})
@@ -248,7 +466,7 @@ func main() {
 				Token:      config.EnsureToken(),
 				Output:     output,
 				OutFn:      outFn,
-				Container:  *cmdGetMeContainer,
+				Container:  config.ContainerOrDefault(*cmdGetMeContainer),
 				Properties: getMeProperties(*cmdGetMeProperties),
 			})
 		}
@@ -266,17 +484,53 @@ func main() {
 
 	// CMD: apply
 	if cmdApply.Happened() {
-		config := conf.EnsureConfig(*cmdApplyUseConfig)
-		api := config.Api
-		token := config.EnsureToken()
-
-		handle.Apply(
-			api,
-			token,
-			*cmdApplyFileName,
-			*cmdApplyDryRun,
-			*cmdApplyPurge,
-		)
+		util.SetErrorFormat(*cmdApplyPlanFormat)
+		config := conf.Resolve(*cmdApplyUseConfig, *cmdApplyTokenFile)
+		configureRequests(config)
+		if *cmdApplyPlanIn == "" && *cmdApplyFileName == "" {
+			util.ExitInputErr("either --filename (-f) or --plan-in is required")
+		}
+		if *cmdApplyPlanIn != "" && (*cmdApplyFileName != "" || *cmdApplyPlanOut != "" || *cmdApplyPlanFormat != "") {
+			util.ExitInputErr("--plan-in cannot be combined with --filename (-f), --plan-out or --plan-format")
+		}
+		if *cmdApplyResume && (*cmdApplyPlanOut != "" || *cmdApplyPlanFormat != "") {
+			util.ExitInputErr("--resume cannot be combined with --plan-out or --plan-format")
+		}
+		if *cmdApplyPlanFormat != "" && !*cmdApplyDryRun {
+			util.ExitInputErr("--plan-format can only be used in combination with --dry-run")
+		}
+		purgeScope := []string{}
+		if *cmdApplyPurgeScope != "" {
+			if !*cmdApplyPurge {
+				util.ExitInputErr("--purge-scope can only be used in combination with --purge")
+			}
+			purgeScope = strings.Split(*cmdApplyPurgeScope, ",")
+		}
+
+		handle.Apply(&handle.TApply{
+			Api:         config.Api,
+			Token:       config.EnsureToken(),
+			Filename:    *cmdApplyFileName,
+			DryRun:      *cmdApplyDryRun,
+			Purge:       *cmdApplyPurge,
+			PurgeAssets: *cmdApplyPurgeAssets,
+			PurgeLabels: slices.Contains(purgeScope, "labels"),
+			PlanFormat:  *cmdApplyPlanFormat,
+			PlanOut:     *cmdApplyPlanOut,
+			PlanIn:      *cmdApplyPlanIn,
+			Atomic:      *cmdApplyAtomic,
+			Resume:      *cmdApplyResume,
+			Parallel:    *cmdApplyParallel,
+			CacheTtl:    config.GetCacheTtl(),
+			MatchBy:     *cmdApplyMatchBy,
+			WriteBack:   *cmdApplyWriteBack,
+
+			Yes:                *cmdApplyYes,
+			ConfirmDestructive: *cmdApplyConfirmDestructive,
+			UseCache:           getAnswer(*cmdApplyUseCache, *cmdApplyNoCache, "use-cache", "no-cache"),
+			RemoteValidate:     getAnswer(*cmdApplyRemoteValidate, *cmdApplyNoRemoteValidate, "remote-validate", "no-remote-validate"),
+			ShowDetails:        getAnswer(*cmdApplyShowDetails, false, "show-details", ""),
+		})
 	}
 	fmt.Println(parser.Usage(nil))
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/akamensky/argparse"
 	"github.com/infrasonar/infrasonar-cli/cli"
@@ -66,11 +67,11 @@ var UseConfig = &argparse.Options{
 		}
 		return nil
 	},
-	Help: fmt.Sprintf("Use an alternative configuration. View the default config with: '%s config default'", filepath.Base(os.Args[0])),
+	Help: fmt.Sprintf("Use an alternative configuration. Overrides the INFRASONAR_CONFIG environment variable. View the default config with: '%s config default'", filepath.Base(os.Args[0])),
 }
 
 var ApplyFileName = &argparse.Options{
-	Required: true,
+	Required: false,
 	Validate: func(args []string) error {
 		fn := args[0]
 		if _, err := os.Stat(fn); errors.Is(err, os.ErrNotExist) {
@@ -79,7 +80,24 @@ var ApplyFileName = &argparse.Options{
 		_, err := cli.GetJsonOrYaml(fn)
 		return err
 	},
-	Help: "YAML of JSON input filename to apply",
+	Help: "YAML of JSON input filename to apply. Required, unless a plan is applied using 'plan-in'",
+}
+
+var PlanOut = &argparse.Options{
+	Required: false,
+	Help:     "Write the planned changes to this file instead of applying them. The plan can be applied later using 'plan-in'",
+}
+
+var PlanIn = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		fn := args[0]
+		if _, err := os.Stat(fn); errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("file does not exist: %s", fn)
+		}
+		return nil
+	},
+	Help: "Apply exactly the changes from a plan file created with 'plan-out'. Refuses to run if the container has changed since the plan was created",
 }
 
 var OutFileName = &argparse.Options{
@@ -104,6 +122,86 @@ var ConfigSetDefault = &argparse.Options{
 	Help:     "Set as the default configuration",
 }
 
+var ConfigRetries = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		if n, err := strconv.Atoi(args[0]); err == nil && n < 0 {
+			return errors.New("expecting a value of 0 or greater")
+		}
+		return nil
+	},
+	Help: "Number of retries for failed requests. Only idempotent requests are retried. Default: 3",
+}
+
+var ConfigContainer = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		if n, err := strconv.Atoi(args[0]); err == nil && n < 0 {
+			return errors.New("expecting a value of 0 or greater")
+		}
+		return nil
+	},
+	Help: "Default container ID for commands without --container (-c). Use 0 to remove the default container",
+}
+
+var ConfigCacheTtl = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		if n, err := strconv.Atoi(args[0]); err == nil && n < 0 {
+			return errors.New("expecting a value of 0 or greater")
+		}
+		return nil
+	},
+	Help: "Time in minutes a cached container state may be reused by apply. Use 0 to disable the cache. Default: 480",
+}
+
+var ConfigTimeout = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		if n, err := strconv.Atoi(args[0]); err == nil && n < 0 {
+			return errors.New("expecting a value of 0 or greater")
+		}
+		return nil
+	},
+	Help: "Timeout in seconds for a single request, 0 for no timeout. Default: 60",
+}
+
+var ConfigRateLimit = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		if n, err := strconv.ParseFloat(args[0], 64); err == nil && n < 0 {
+			return errors.New("expecting a value of 0 or greater")
+		}
+		return nil
+	},
+	Help: "Maximum number of requests per second, 0 for no limit. Default: 0",
+}
+
+var ConfigProxy = &argparse.Options{
+	Required: false,
+	Help:     "Proxy URL, for example http://proxy.local:3128. An empty value removes the proxy. Default: HTTPS_PROXY from the environment",
+}
+
+var ConfigCaBundle = &argparse.Options{
+	Required: false,
+	Help:     "PEM file with CA certificates to trust in addition to the system certificates. An empty value removes the CA bundle",
+}
+
+var ConfigClientCert = &argparse.Options{
+	Required: false,
+	Help:     "PEM file with a client certificate, requires --set-client-key. An empty value removes the client certificate",
+}
+
+var ConfigClientKey = &argparse.Options{
+	Required: false,
+	Help:     "PEM file with the private key for the client certificate. An empty value removes the key",
+}
+
+var ConfigInsecureSkipVerify = &argparse.Options{
+	Required: false,
+	Help:     "Do not verify the TLS certificate of the API. Only use this for testing. {true,false}",
+}
+
 var ConfigListMore = &argparse.Options{
 	Required: false,
 	Help:     "List with more detailed configuration information",
@@ -143,17 +241,24 @@ var Token = &argparse.Options{
 	Help: "Token for authentication with the InfraSonar API",
 }
 
+var TokenFile = &argparse.Options{
+	Required: false,
+	Help:     "Read the token from a file instead of the configuration. Overrides the INFRASONAR_TOKEN environment variable",
+}
+
 var Container = &argparse.Options{
 	Required: false,
 	Validate: func(args []string) error {
-		if containerId, err := strconv.Atoi(args[0]); err == nil {
-			if containerId <= 0 {
-				return errors.New("expecting a value greater than 0")
-			}
+		containerId, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errors.New("expecting a container ID")
+		}
+		if containerId <= 0 {
+			return errors.New("expecting a value greater than 0")
 		}
 		return nil
 	},
-	Help: "Container ID",
+	Help: "Container ID. Default: the container of the configuration or the container token",
 }
 
 var Asset = &argparse.Options{
@@ -174,11 +279,104 @@ var Purge = &argparse.Options{
 	Help:     "Deletes existing labels and collectors if not specified. Without the 'purge' flag, only new labels, collectors, and configuration changes are applied",
 }
 
+var PurgeAssets = &argparse.Options{
+	Required: false,
+	Help:     "Deletes assets from the container which are not specified in the input file. This cannot be undone and requires an additional confirmation",
+}
+
 var DryRun = &argparse.Options{
 	Required: false,
 	Help:     "Dry run mode. Simulate the changes that would be made without actually applying them. Displays a list of proposed changes",
 }
 
+var PlanFormat = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		switch args[0] {
+		case "json", "yaml":
+			return nil
+		}
+
+		return fmt.Errorf("unknown '%s' {json,yaml}", args[0])
+	},
+	Help: "Write the planned changes to stdout in a machine-readable format. Requires the 'dry-run' flag. {json,yaml}",
+}
+
+var Atomic = &argparse.Options{
+	Required: false,
+	Help:     "When a change fails, revert all changes which are already applied in reverse order. Deleted assets and labels cannot be restored",
+}
+
+var Parallel = &argparse.Options{
+	Required: false,
+	Default:  1,
+	Validate: func(args []string) error {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if n <= 0 || n > 32 {
+				return errors.New("expecting a value between 1 and 32")
+			}
+		}
+		return nil
+	},
+	Help: "Number of tasks to run concurrently. Zones, labels and collectors are processed first and tasks for a single asset always run in order",
+}
+
+var MatchBy = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		if args[0] == "name" || (strings.HasPrefix(args[0], "property:") && len(args[0]) > len("property:")) {
+			return nil
+		}
+		return errors.New("expecting name or property:<key>")
+	},
+	Help: "Match assets without an ID against existing assets by name or by a property (property:<key>). A matching asset is updated instead of created; an ambiguous match is an error",
+}
+
+var WriteBack = &argparse.Options{
+	Required: false,
+	Help:     "Write the IDs of created and matched assets and labels to the input file. Comments and formatting are preserved",
+}
+
+var Resume = &argparse.Options{
+	Required: false,
+	Help:     "Resume an interrupted apply for the container. Tasks which are already done are skipped, no new changes are read from the input file",
+}
+
+var Yes = &argparse.Options{
+	Required: false,
+	Help:     "Answer yes to all questions, unless answered by another flag. Does not use a cache and does not confirm destructive changes. Required when stdin is not a terminal",
+}
+
+var ConfirmDestructive = &argparse.Options{
+	Required: false,
+	Help:     "Confirm destructive changes, such as deleting assets, zones or labels, without asking",
+}
+
+var UseCache = &argparse.Options{
+	Required: false,
+	Help:     "Use a recent cache of the container state when available, without asking",
+}
+
+var NoCache = &argparse.Options{
+	Required: false,
+	Help:     "Never use a cache of the container state",
+}
+
+var RemoteValidate = &argparse.Options{
+	Required: false,
+	Help:     "Validate collector configurations remotely, without asking",
+}
+
+var NoRemoteValidate = &argparse.Options{
+	Required: false,
+	Help:     "Validate collector configurations locally only, without asking",
+}
+
+var ShowDetails = &argparse.Options{
+	Required: false,
+	Help:     "Show the details of all changes, without asking",
+}
+
 var AssetFilter = &argparse.Options{
 	Required: false,
 	Validate: func(args []string) error {
@@ -231,8 +429,70 @@ var CollectorProperties = selectorList(
 	"Collector properties to return (comma-separated). If omitted, all properties will be returned",
 )
 
+var PurgeScope = selectorList(
+	false,
+	[]string{"labels"},
+	"Additionally delete labels which are not specified in the input file and are no longer used by any asset after applying. Zones are not supported. Requires the 'purge' flag",
+)
+
 var MeProperties = selectorList(
 	false,
 	cli.MeProperties,
 	"Info properties to return (comma-separated). If omitted, all properties will be returned",
 )
+
+var Verbose = &argparse.Options{
+	Required: false,
+	Help:     "Log each API request with the method, URL, status and duration to stderr",
+}
+
+var Debug = &argparse.Options{
+	Required: false,
+	Help:     "Like verbose, but also log request and response headers and bodies. Tokens and secrets are redacted",
+}
+
+var TraceFile = &argparse.Options{
+	Required: false,
+	Help:     "Write all API requests to a HAR file, for example to attach to a support ticket. Tokens and secrets are redacted",
+}
+
+var ConfigRekeyMethod = &argparse.Options{
+	Required: false,
+	Default:  "passphrase",
+	Help:     "Protect the tokens with a passphrase, a key file or the builtin key. The new passphrase can be given with the INFRASONAR_NEW_PASSPHRASE environment variable",
+}
+
+var ConfigRekeyKeyFile = &argparse.Options{
+	Required: false,
+	Help:     "Key file for the key-file method, outside the configuration directory. A new key file is created if the file does not exist",
+}
+
+var ConfigTestContainer = &argparse.Options{
+	Required: false,
+	Validate: Container.Validate,
+	Help:     "Container ID to check the permissions for. Default: the container of the configuration or the container token",
+}
+
+var WhoamiOutput = &argparse.Options{
+	Required: false,
+	Validate: func(args []string) error {
+		switch args[0] {
+		case "json", "yaml":
+			return nil
+		}
+		return errors.New("expecting json or yaml")
+	},
+	Help: "Output format (json or yaml). Default: text",
+}
+
+var CacheContainer = &argparse.Options{
+	Required: false,
+	Validate: Container.Validate,
+	Help:     "Container ID",
+}
+
+var CacheShowContainer = &argparse.Options{
+	Required: true,
+	Validate: Container.Validate,
+	Help:     "Container ID",
+}
@@ -0,0 +1,93 @@
+package req
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+	"github.com/infrasonar/infrasonar-cli/infrasonar"
+)
+
+const (
+	DefaultRetries = infrasonar.DefaultRetries
+	DefaultTimeout = infrasonar.DefaultTimeout
+)
+
+// Settings control how requests are made. Use Configure to apply settings
+// from a configuration.
+type Settings struct {
+	Retries   int           // Number of retries after the first attempt
+	Timeout   time.Duration // Timeout for a single attempt, 0 for no timeout
+	RateLimit float64       // Maximum requests per second, 0 for no limit
+
+	Proxy              string // Proxy URL, default from HTTPS_PROXY/HTTP_PROXY
+	CaBundle           string // PEM file with CA certificates, trusted in addition to the system roots
+	ClientCert         string // PEM file with a client certificate
+	ClientKey          string // PEM file with the key for the client certificate
+	InsecureSkipVerify bool   // Do not verify the certificate of the API
+}
+
+// MaxConnsPerHost limits the number of connections to the API, idle
+// connections are kept open and reused by later requests.
+const MaxConnsPerHost = 16
+
+// baseTransport holds the connections and the proxy and TLS settings.
+var baseTransport = newTransport()
+
+var transport = &infrasonar.RetryTransport{
+	Base:    baseTransport,
+	Retries: DefaultRetries,
+	Timeout: DefaultTimeout,
+}
+
+// httpClient is shared by all requests so connections are reused.
+var httpClient = &http.Client{Transport: transport}
+
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = MaxConnsPerHost * 2
+	t.MaxIdleConnsPerHost = MaxConnsPerHost
+	t.MaxConnsPerHost = MaxConnsPerHost
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
+
+// Configure must be called before any request is made.
+func Configure(s Settings) error {
+	proxy, err := proxyFunc(s.Proxy)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := newTLSConfig(s)
+	if err != nil {
+		return err
+	}
+	baseTransport.Proxy = proxy
+	baseTransport.TLSClientConfig = tlsConfig
+
+	transport.Retries = s.Retries
+	transport.Timeout = s.Timeout
+	transport.RateLimit = s.RateLimit
+	return nil
+}
+
+// Validate returns an error if the proxy or TLS settings cannot be used.
+func (s Settings) Validate() error {
+	if _, err := proxyFunc(s.Proxy); err != nil {
+		return err
+	}
+	_, err := newTLSConfig(s)
+	return err
+}
+
+// Client returns an API client which uses the shared connections and
+// settings of the CLI.
+func Client(api, token string) *infrasonar.Client {
+	return &infrasonar.Client{
+		BaseURL:    api,
+		Token:      token,
+		HTTPClient: httpClient,
+		UserAgent:  fmt.Sprintf("InfraSonarCli/%s", cli.Version),
+	}
+}
@@ -0,0 +1,410 @@
+package req
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infrasonar/infrasonar-cli/cli"
+)
+
+const (
+	TraceOff     = iota
+	TraceVerbose // Method, URL, status and duration
+	TraceDebug   // Also headers and bodies
+)
+
+const redacted = "xxx"
+
+// traceTransport logs every attempt of a request to stderr and optionally
+// to a HAR file. Tokens and secret configuration values are redacted.
+type traceTransport struct {
+	base  http.RoundTripper
+	level int
+	har   *harFile
+}
+
+// Trace enables logging of requests. When traceFile is not empty, requests
+// are also written to this file in HAR format.
+func Trace(level int, traceFile string) error {
+	if level == TraceOff && traceFile == "" {
+		return nil
+	}
+	t := &traceTransport{
+		base:  transport.Base,
+		level: level,
+	}
+	if traceFile != "" {
+		har, err := newHarFile(traceFile)
+		if err != nil {
+			return err
+		}
+		t.har = har
+	}
+	transport.Base = t
+	return nil
+}
+
+func (t *traceTransport) logf(format string, a ...any) {
+	fmt.Fprintf(os.Stderr, "[http] "+format+"\n", a...)
+}
+
+func (t *traceTransport) logHeader(prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		t.logf("%s %s: %s", prefix, k, strings.Join(header[k], ", "))
+	}
+}
+
+// readRequestBody returns the body of a request. A RoundTripper must not
+// modify the request, so the body is read from GetBody when possible, or
+// the request is cloned otherwise.
+func readRequestBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		return req, data, err
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(data))
+	return clone, data, nil
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.har != nil {
+		// Fail instead of making requests which are missing in the trace
+		if err := t.har.failed(); err != nil {
+			return nil, err
+		}
+	}
+
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody && (t.level >= TraceDebug || t.har != nil) {
+		var err error
+		req, reqBody, err = readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = redactBody(reqBody)
+	}
+
+	if t.level >= TraceDebug {
+		t.logf("> %s %s", req.Method, req.URL)
+		t.logHeader(">", redactHeader(req.Header))
+		if len(reqBody) > 0 {
+			t.logf("> %s", reqBody)
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		duration := time.Since(start)
+		if t.level >= TraceVerbose {
+			t.logf("%s %s failed after %s: %s", req.Method, req.URL, duration.Round(time.Millisecond), err)
+		}
+		if t.har != nil {
+			t.har.add(req, reqBody, nil, nil, start, duration, err)
+		}
+		return nil, err
+	}
+
+	var respBody []byte
+	if t.level >= TraceDebug || t.har != nil {
+		respBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	}
+	duration := time.Since(start)
+
+	if t.level >= TraceVerbose {
+		t.logf("%s %s -> %s (%s)", req.Method, req.URL, resp.Status, duration.Round(time.Millisecond))
+	}
+	if t.level >= TraceDebug {
+		t.logHeader("<", resp.Header)
+		if len(respBody) > 0 {
+			t.logf("< %s", redactBody(respBody))
+		}
+	}
+	if t.har != nil {
+		t.har.add(req, reqBody, resp, redactBody(respBody), start, duration, nil)
+	}
+	return resp, nil
+}
+
+func redactHeader(header http.Header) http.Header {
+	clone := header.Clone()
+	if clone.Get("Authorization") != "" {
+		clone.Set("Authorization", "Bearer "+redacted)
+	}
+	return clone
+}
+
+// redactBody replaces secret values in a JSON body. Bodies which are not
+// JSON are returned as is.
+func redactBody(body []byte) []byte {
+	var v any
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	if !redact(v) {
+		return body
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// redact replaces secret values in place and returns true if any value
+// was replaced.
+func redact(v any) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]any:
+		for k, value := range t {
+			if cli.IsSecretKey(k) {
+				t[k] = redacted
+				changed = true
+			} else if redact(value) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, value := range t {
+			if redact(value) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// harFile is written incrementally: every entry is appended to the entries
+// list, followed by the end of the document, so the file is complete even
+// when the CLI exits early.
+type harFile struct {
+	mu     sync.Mutex
+	fn     string
+	fp     *os.File
+	tail   []byte // End of the document, after the entries list
+	offset int64  // Position of the tail in the file
+	n      int    // Number of entries written
+	err    error  // Set when writing fails; no more requests are made
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHarFile(fn string) (*harFile, error) {
+	log := harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "InfraSonarCli", Version: cli.Version},
+		Entries: []harEntry{},
+	}
+	data, err := json.MarshalIndent(map[string]any{"log": &log}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	fp, err := os.OpenFile(fn, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace file '%s': %s", fn, err)
+	}
+	if _, err := fp.Write(data); err != nil {
+		fp.Close()
+		return nil, fmt.Errorf("failed to write trace file '%s': %s", fn, err)
+	}
+	// The entries list is the last field, the tail starts at its "]"
+	i := bytes.LastIndex(data, []byte("[]")) + 1
+	return &harFile{
+		fn:     fn,
+		fp:     fp,
+		tail:   append([]byte("\n    "), data[i:]...),
+		offset: int64(i),
+	}, nil
+}
+
+// failed returns an error when the trace file can no longer be written.
+func (h *harFile) failed() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// write appends an entry and the tail at the end of the entries list.
+func (h *harFile) write(entry *harEntry) error {
+	data, err := json.MarshalIndent(entry, "      ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n      "
+	if h.n == 0 {
+		sep = "\n      "
+	}
+	chunk := slices.Concat([]byte(sep), data, h.tail)
+	if _, err := h.fp.WriteAt(chunk, h.offset); err != nil {
+		return fmt.Errorf("failed to write trace file '%s': %s", h.fn, err)
+	}
+	h.offset += int64(len(chunk) - len(h.tail))
+	h.n += 1
+	return nil
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for k, values := range header {
+		for _, v := range values {
+			headers = append(headers, harNameValue{Name: k, Value: v})
+		}
+	}
+	return headers
+}
+
+func (h *harFile) add(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, start time.Time, duration time.Duration, failure error) {
+	ms := float64(duration.Microseconds()) / 1000
+	entry := harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            ms,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(redactHeader(req.Header)),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Timings: harTimings{Wait: ms},
+	}
+	for k, values := range req.URL.Query() {
+		for _, v := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: k, Value: v})
+		}
+	}
+	if reqBody != nil {
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(reqBody),
+		}
+	}
+	if failure == nil {
+		entry.Response = harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(resp.Header),
+			Content: harContent{
+				Size:     len(respBody),
+				MimeType: resp.Header.Get("Content-Type"),
+				Text:     string(respBody),
+			},
+			HeadersSize: -1,
+			BodySize:    len(respBody),
+		}
+	} else {
+		// The request failed without a response
+		entry.Response = harResponse{
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+		entry.Comment = fmt.Sprintf("request failed: %s", failure)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err == nil {
+		h.err = h.write(&entry)
+	}
+}
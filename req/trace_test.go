@@ -0,0 +1,101 @@
+package req
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", "", ""},
+		{"not json", "password=secret", "password=secret"},
+		{"no secrets", `{"name": "web1", "id": 11}`, `{"name": "web1", "id": 11}`},
+		{"password", `{"password":"abc","user":"admin"}`, `{"password":"xxx","user":"admin"}`},
+		{"secret", `{"secret":123}`, `{"secret":"xxx"}`},
+		{"nested", `{"config":{"address":"10.0.0.1","password":"abc"}}`, `{"config":{"address":"10.0.0.1","password":"xxx"}}`},
+		{"in a list", `[{"key":"snmp","config":{"secret":"abc"}},{"key":"tcp"}]`, `[{"config":{"secret":"xxx"},"key":"snmp"},{"key":"tcp"}]`},
+		{"key is case sensitive", `{"Password":"abc"}`, `{"Password":"abc"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(redactBody([]byte(tt.body))); got != tt.want {
+				t.Errorf("redactBody = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer 0123456789abcdef")
+	header.Set("Content-Type", "application/json")
+
+	got := redactHeader(header)
+	if v := got.Get("Authorization"); v != "Bearer xxx" {
+		t.Errorf("Authorization = %q", v)
+	}
+	if v := got.Get("Content-Type"); v != "application/json" {
+		t.Errorf("Content-Type = %q", v)
+	}
+	if v := header.Get("Authorization"); v != "Bearer 0123456789abcdef" {
+		t.Errorf("original header changed to %q", v)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTraceTransportKeepsRequest(t *testing.T) {
+	const body = `{"name": "web"}`
+	tests := []struct {
+		name    string
+		getBody bool
+	}{
+		{"with GetBody", true},
+		{"without GetBody", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent string
+			tr := &traceTransport{
+				base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					data, _ := io.ReadAll(req.Body)
+					sent = string(data)
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+				}),
+				level: TraceDebug,
+			}
+			req, err := http.NewRequest("POST", "https://api.infrasonar.com/asset/11/name", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.getBody {
+				req.GetBody = nil
+			}
+			original := req.Body
+
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if sent != body {
+				t.Errorf("sent body = %q, want %q", sent, body)
+			}
+			if req.Body != original {
+				t.Error("the body of the request is replaced")
+			}
+		})
+	}
+}